@@ -0,0 +1,158 @@
+/*
+ * SPDX-License-Identifier: GPL-3.0
+ * Vencord Installer, a cross platform gui/cli app for installing Vencord
+ * Copyright (c) 2023 Vendicated and Vencord contributors
+ */
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	path "path/filepath"
+	"runtime"
+	"strings"
+)
+
+// AssetRule decides which release assets are installed
+type AssetRule struct {
+	// Glob matched against the asset name, like "renderer.js" or "*.map"
+	Pattern string `json:"pattern"`
+	// Refuse to install the release if no asset matches
+	Required bool `json:"required,omitempty"`
+	// Only install the asset on these operating systems (GOOS names like "windows" or "linux"). Empty means everywhere
+	Platforms []string `json:"platforms,omitempty"`
+}
+
+// AssetManifest can be published with a release as AssetManifestName to replace DefaultAssetRules,
+// so new files can be shipped without updating the installer
+type AssetManifest struct {
+	Assets []AssetRule `json:"assets"`
+}
+
+// DefaultAssetRules are used for releases without an AssetManifest
+var DefaultAssetRules = []AssetRule{
+	{Pattern: "patcher.js", Required: true},
+	{Pattern: "preload.js", Required: true},
+	{Pattern: "renderer.js", Required: true},
+	{Pattern: "renderer.css", Required: true},
+	{Pattern: "*.map"},
+	{Pattern: "*.LEGAL.txt"},
+}
+
+func (r *AssetRule) Validate() error {
+	if _, err := path.Match(r.Pattern, ""); err != nil || r.Pattern == "" {
+		return errors.New("Invalid asset pattern '" + r.Pattern + "'")
+	}
+	return nil
+}
+
+// AppliesHere reports whether the rule is meant for the current operating system
+func (r *AssetRule) AppliesHere() bool {
+	if len(r.Platforms) == 0 {
+		return true
+	}
+	for _, platform := range r.Platforms {
+		if platform == runtime.GOOS {
+			return true
+		}
+	}
+	return false
+}
+
+func (r *AssetRule) Matches(name string) bool {
+	ok, _ := path.Match(r.Pattern, name)
+	return ok && r.AppliesHere()
+}
+
+// MatchesAssetRules reports whether the asset called name should be installed according to rules
+func MatchesAssetRules(rules []AssetRule, name string) bool {
+	// Never install assets into subfolders, they could escape the version folder
+	if name != path.Base(name) || strings.ContainsAny(name, `/\`) {
+		return false
+	}
+	for i := range rules {
+		if rules[i].Matches(name) {
+			return true
+		}
+	}
+	return false
+}
+
+// SelectAssets returns the assets of the release that rules want installed.
+// Fails if an asset required on this platform is missing
+func SelectAssets(release *GithubRelease, rules []AssetRule) ([]ReleaseAsset, error) {
+	var selected []ReleaseAsset
+	for _, ass := range release.Assets {
+		if MatchesAssetRules(rules, ass.Name) {
+			selected = append(selected, ass)
+		}
+	}
+
+	for i := range rules {
+		rule := &rules[i]
+		if !rule.Required || !rule.AppliesHere() {
+			continue
+		}
+
+		found := false
+		for _, ass := range selected {
+			if rule.Matches(ass.Name) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return nil, errors.New("The release has no asset matching " + rule.Pattern + ". Not installing an incomplete build")
+		}
+	}
+
+	return selected, nil
+}
+
+// IsRequiredAsset reports whether the asset called name matches a rule that is required on this platform
+func IsRequiredAsset(rules []AssetRule, name string) bool {
+	for i := range rules {
+		if rules[i].Required && rules[i].Matches(name) {
+			return true
+		}
+	}
+	return false
+}
+
+// GetAssetRules returns the rules from the release's AssetManifestName, or DefaultAssetRules if it has none
+func GetAssetRules(release *GithubRelease) ([]AssetRule, error) {
+	ass := release.FindAsset(AssetManifestName)
+	if ass == nil {
+		return DefaultAssetRules, nil
+	}
+
+	LogInfo("Fetching asset manifest from", ass.DownloadURL)
+	req, err := NewRequest(context.Background(), ass.DownloadURL)
+	if err != nil {
+		return nil, err
+	}
+	res, err := HttpClient.Do(req)
+	if err == nil && res.StatusCode >= 300 {
+		res.Body.Close()
+		err = NewHttpStatusError(ass.DownloadURL, res)
+	}
+	if err != nil {
+		return nil, errors.New("Failed to fetch " + AssetManifestName + ": " + err.Error())
+	}
+	defer res.Body.Close()
+
+	var manifest AssetManifest
+	if err = json.NewDecoder(io.LimitReader(res.Body, 1<<20)).Decode(&manifest); err != nil {
+		return nil, errors.New("Failed to parse " + AssetManifestName + ": " + err.Error())
+	}
+	for i := range manifest.Assets {
+		if err = manifest.Assets[i].Validate(); err != nil {
+			return nil, errors.New("Invalid rule in " + AssetManifestName + ": " + err.Error())
+		}
+	}
+
+	return manifest.Assets, nil
+}
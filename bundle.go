@@ -0,0 +1,145 @@
+/*
+ * SPDX-License-Identifier: GPL-3.0
+ * Vencord Installer, a cross platform gui/cli app for installing Vencord
+ * Copyright (c) 2023 Vendicated and Vencord contributors
+ */
+
+package main
+
+import (
+	"archive/zip"
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"io"
+	"io/fs"
+	"os"
+	path "path/filepath"
+)
+
+// BundleManifestName is the manifest every offline bundle has to contain next to the build files
+const BundleManifestName = "manifest.json"
+
+// BundleManifest describes the build in an offline bundle
+type BundleManifest struct {
+	// Hash of the build, used as InstalledHash
+	Version string `json:"version"`
+	// Optional sha256 digests of the files, keyed by file name. If present, every file has to match
+	Files map[string]string `json:"files,omitempty"`
+}
+
+// BundlePath is a zip file or folder to install Venticord from instead of downloading it
+var BundlePath string
+
+// InstallFromBundle installs the build in the zip file or folder at p
+func InstallFromBundle(p string) error {
+	version, err := installBundle(p, "")
+	if err != nil {
+		return err
+	}
+
+	// The bundle is the newest version we know of, so nothing needs to be downloaded
+	LatestHash = version
+	LogInfo("Done!")
+	return nil
+}
+
+// installBundle installs the build in the bundle at p and returns its version.
+// If version isn't empty, the bundle has to contain that version
+func installBundle(p, version string) (_ string, retErr error) {
+	LogInfo("Installing from bundle", p)
+
+	var bundle fs.FS
+	if IsDirectory(p) {
+		bundle = os.DirFS(p)
+	} else {
+		zipFile, err := zip.OpenReader(p)
+		if err != nil {
+			return "", errors.New("Failed to open bundle " + p + ": " + err.Error())
+		}
+		defer zipFile.Close()
+		bundle = zipFile
+	}
+
+	b, err := fs.ReadFile(bundle, BundleManifestName)
+	if err != nil {
+		return "", errors.New("Bundle " + p + " has no " + BundleManifestName + ": " + err.Error())
+	}
+
+	var manifest BundleManifest
+	if err = json.Unmarshal(b, &manifest); err != nil {
+		return "", errors.New("Failed to parse " + BundleManifestName + ": " + err.Error())
+	}
+
+	if version != "" && manifest.Version != version {
+		return "", errors.New("Bundle " + p + " contains version " + manifest.Version + " instead of " + version)
+	}
+
+	if plan := dryRunPlan(); plan != nil {
+		plan.PlanInstall(manifest.Version, p, nil)
+		return manifest.Version, nil
+	}
+
+	stagingDir, err := prepareStaging(manifest.Version)
+	if err != nil {
+		return "", err
+	}
+	defer func() {
+		if retErr != nil {
+			_ = os.RemoveAll(stagingDir)
+		}
+	}()
+
+	entries, err := fs.ReadDir(bundle, ".")
+	if err != nil {
+		return "", err
+	}
+
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || !IsDistFile(name) {
+			continue
+		}
+
+		LogInfo("Extracting", name)
+		sum, err := copyBundleFile(bundle, name, path.Join(stagingDir, name))
+		if err != nil {
+			return "", errors.New("Failed to extract " + name + ": " + err.Error())
+		}
+
+		if len(manifest.Files) != 0 {
+			if err = VerifyChecksum(manifest.Files, name, sum); err != nil {
+				LogWarn(err)
+				return "", err
+			}
+			LogDebug("Verified checksum of", name)
+		}
+	}
+
+	source, _ := path.Abs(p)
+	if err = commitStaging(stagingDir, manifest.Version, source); err != nil {
+		return "", err
+	}
+	return manifest.Version, nil
+}
+
+// copyBundleFile copies the file name from the bundle to dest and returns its sha256
+func copyBundleFile(bundle fs.FS, name, dest string) ([]byte, error) {
+	in, err := bundle.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer in.Close()
+
+	out, err := os.Create(dest)
+	if err != nil {
+		return nil, err
+	}
+	defer out.Close()
+
+	hash := sha256.New()
+	if _, err = io.Copy(io.MultiWriter(out, hash), in); err != nil {
+		return nil, err
+	}
+	return hash.Sum(nil), nil
+}
@@ -0,0 +1,156 @@
+/*
+ * SPDX-License-Identifier: GPL-3.0
+ * Vencord Installer, a cross platform gui/cli app for installing Vencord
+ * Copyright (c) 2023 Vendicated and Vencord contributors
+ */
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// MaxChangelogCommits limits how many commits are listed in the changelog
+const MaxChangelogCommits = 50
+
+type GithubCommit struct {
+	Sha     string `json:"sha"`
+	HtmlUrl string `json:"html_url"`
+	Commit  struct {
+		Message string `json:"message"`
+		Author  struct {
+			Name string    `json:"name"`
+			Date time.Time `json:"date"`
+		} `json:"author"`
+	} `json:"commit"`
+}
+
+// GithubComparison is the response of GitHub's compare API
+type GithubComparison struct {
+	Status       string         `json:"status"`
+	AheadBy      int            `json:"ahead_by"`
+	BehindBy     int            `json:"behind_by"`
+	TotalCommits int            `json:"total_commits"`
+	Commits      []GithubCommit `json:"commits"`
+}
+
+// CompareUrl returns GitHub's compare API of the repository the release JSON at releaseUrl belongs to, like
+// https://api.github.com/repos/Venticord/Venticord/compare/ for its latest release. Other sources have none
+func CompareUrl(releaseUrl string) (string, bool) {
+	u, err := url.Parse(releaseUrl)
+	if err != nil || u.Scheme != "https" || u.Host != GithubApiHost {
+		return "", false
+	}
+
+	parts := strings.Split(strings.TrimPrefix(u.Path, "/"), "/")
+	if len(parts) < 4 || parts[0] != "repos" || parts[3] != "releases" {
+		return "", false
+	}
+	return "https://" + GithubApiHost + "/repos/" + parts[1] + "/" + parts[2] + "/compare/", true
+}
+
+// FetchCommitRange fetches the commits between the builds base and head from the compare API at apiUrl, oldest first
+func FetchCommitRange(apiUrl, base, head string) (*GithubComparison, error) {
+	compareUrl := apiUrl + url.PathEscape(base) + "..." + url.PathEscape(head)
+	LogInfo("Fetching", compareUrl)
+
+	req, err := NewRequest(context.Background(), compareUrl)
+	if err != nil {
+		return nil, err
+	}
+	res, err := HttpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode >= 300 {
+		return nil, NewHttpStatusError(compareUrl, res)
+	}
+
+	var data GithubComparison
+	if err = json.NewDecoder(res.Body).Decode(&data); err != nil {
+		return nil, err
+	}
+	return &data, nil
+}
+
+// canCompare reports whether hash is a Venticord commit, and not a local build or placeholder
+func canCompare(hash string) bool {
+	return IsValidVersion(hash) && hash != "None" && hash != "Unknown" && !strings.HasPrefix(hash, "dev-")
+}
+
+// GetChangelog renders the release notes of ReleaseData and the commits since InstalledHash as markdown
+func GetChangelog() string {
+	var sb strings.Builder
+
+	sb.WriteString("# " + Ternary(ReleaseData.Name != "", ReleaseData.Name, LatestHash) + "\n")
+	if !ReleaseData.PublishedAt.IsZero() {
+		sb.WriteString("Published " + ReleaseData.PublishedAt.Local().Format("2006-01-02 15:04") + "\n")
+	}
+	if ReleaseData.HtmlUrl != "" {
+		sb.WriteString("[View on GitHub](" + ReleaseData.HtmlUrl + ")\n")
+	}
+	sb.WriteString("\n")
+
+	body := strings.TrimSpace(strings.ReplaceAll(ReleaseData.Body, "\r\n", "\n"))
+	sb.WriteString(Ternary(body != "", body, "This release has no release notes.") + "\n\n")
+
+	apiUrl, isGithub := CompareUrl(ReleaseData.SourceUrl)
+	switch {
+	case InstalledHash == LatestHash:
+		sb.WriteString("You are already on this version.\n")
+	case InstalledHash == "None":
+		// Nothing installed yet, so there are no changes to list
+	case !canCompare(InstalledHash) || !canCompare(LatestHash):
+		sb.WriteString("Can't list the changes since your version " + InstalledHash + ".\n")
+	case !isGithub:
+		sb.WriteString("Can't list the changes since your version " + InstalledHash + " as this release isn't from GitHub.\n")
+	default:
+		sb.WriteString("## Changes since " + InstalledHash + "\n")
+		sb.WriteString(renderCommitRange(apiUrl, InstalledHash, LatestHash))
+	}
+
+	return sb.String()
+}
+
+func renderCommitRange(apiUrl, base, head string) string {
+	comparison, err := FetchCommitRange(apiUrl, base, head)
+	if err != nil {
+		LogWarn("Failed to fetch commits:", err)
+		return "Failed to fetch the commits: " + err.Error() + "\n"
+	}
+
+	if comparison.BehindBy > 0 && comparison.AheadBy == 0 {
+		return "Your version " + base + " is newer than " + head + ". Updating would downgrade you.\n"
+	}
+	if len(comparison.Commits) == 0 {
+		return "No commits.\n"
+	}
+
+	commits := comparison.Commits
+	if len(commits) > MaxChangelogCommits {
+		commits = commits[len(commits)-MaxChangelogCommits:]
+	}
+
+	var sb strings.Builder
+	// Newest first
+	for i := len(commits) - 1; i >= 0; i-- {
+		c := &commits[i]
+		title, _, _ := strings.Cut(c.Commit.Message, "\n")
+		sha := c.Sha
+		if len(sha) > 7 {
+			sha = sha[:7]
+		}
+		sb.WriteString("- [" + sha + "](" + c.HtmlUrl + ") " + title + " (" + c.Commit.Author.Name + ")\n")
+	}
+	if more := comparison.TotalCommits - len(commits); more > 0 {
+		sb.WriteString("- ... and " + strconv.Itoa(more) + " more\n")
+	}
+	return sb.String()
+}
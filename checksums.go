@@ -0,0 +1,108 @@
+/*
+ * SPDX-License-Identifier: GPL-3.0
+ * Vencord Installer, a cross platform gui/cli app for installing Vencord
+ * Copyright (c) 2023 Vendicated and Vencord contributors
+ */
+
+package main
+
+import (
+	"bufio"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"strings"
+)
+
+// GetReleaseChecksums returns the expected sha256 (hex) of every asset of the release, keyed by asset name.
+// Digests embedded in the release JSON are preferred. Assets without one are looked up in the
+// ChecksumsAssetName manifest published alongside the release, if there is one
+func GetReleaseChecksums(release *GithubRelease) (map[string]string, error) {
+	checksums := make(map[string]string)
+
+	var manifestUrl string
+	for _, ass := range release.Assets {
+		if ass.Name == ChecksumsAssetName {
+			manifestUrl = ass.DownloadURL
+		}
+		if algo, digest, ok := strings.Cut(ass.Digest, ":"); ok && algo == "sha256" {
+			checksums[ass.Name] = strings.ToLower(digest)
+		}
+	}
+
+	if manifestUrl == "" {
+		return checksums, nil
+	}
+
+	LogInfo("Fetching checksums from", manifestUrl)
+	req, err := NewRequest(context.Background(), manifestUrl)
+	if err != nil {
+		return nil, err
+	}
+	res, err := HttpClient.Do(req)
+	if err == nil && res.StatusCode >= 300 {
+		res.Body.Close()
+		err = NewHttpStatusError(manifestUrl, res)
+	}
+	if err != nil {
+		return nil, errors.New("Failed to fetch " + ChecksumsAssetName + ": " + err.Error())
+	}
+	defer res.Body.Close()
+
+	manifest, err := ParseChecksums(res.Body)
+	if err != nil {
+		return nil, errors.New("Failed to parse " + ChecksumsAssetName + ": " + err.Error())
+	}
+	for name, sum := range manifest {
+		if _, ok := checksums[name]; !ok {
+			checksums[name] = sum
+		}
+	}
+
+	return checksums, nil
+}
+
+// ParseChecksums parses sha256sum style output, that is one "<hex digest>  <file name>" pair per line
+func ParseChecksums(r io.Reader) (map[string]string, error) {
+	checksums := make(map[string]string)
+
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+
+		fields := strings.Fields(line)
+		if len(fields) != 2 {
+			return nil, errors.New("Malformed line: " + line)
+		}
+
+		sum := strings.ToLower(fields[0])
+		if _, err := hex.DecodeString(sum); err != nil || len(sum) != sha256.Size*2 {
+			return nil, errors.New("Invalid sha256 digest: " + fields[0])
+		}
+
+		// sha256sum marks binary mode with a leading asterisk
+		checksums[strings.TrimPrefix(fields[1], "*")] = sum
+	}
+
+	return checksums, scanner.Err()
+}
+
+// VerifyChecksum checks the sha256 sum of the asset called name against the expected one
+func VerifyChecksum(checksums map[string]string, name string, sum []byte) error {
+	expected, ok := checksums[name]
+	if !ok {
+		return errors.New("No checksum was published for " + name + ". Refusing to install it")
+	}
+
+	actual := hex.EncodeToString(sum)
+	if actual != expected {
+		return errors.New("Checksum mismatch for " + name + ": expected " + expected + ", got " + actual + ". Refusing to install it")
+	}
+
+	return nil
+}
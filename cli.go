@@ -9,13 +9,22 @@
 package main
 
 import (
-	"flag"
+	"errors"
 	"fmt"
+	"io"
 	"os"
+	"strings"
+	"sync"
+	"text/tabwriter"
 )
 
 var discords []any
 
+var nonInteractive bool
+
+var downloadTracker DownloadTracker
+var progressLock sync.Mutex
+
 func isValidBranch(branch string) bool {
 	switch branch {
 	case "", "stable", "ptb", "canary", "auto":
@@ -25,74 +34,118 @@ func isValidBranch(branch string) bool {
 	}
 }
 
+// die exits because of invalid flags
 func die(msg string) {
-	fmt.Println(msg)
-	os.Exit(1)
+	exit(ExitUsage, errors.New(msg))
 }
 
 func main() {
-	InitGithubDownloader()
 	discords = FindDiscords()
 
-	var installFlag = flag.Bool("install", false, "Install Venticord on a Discord install")
-	var updateFlag = flag.Bool("reinstall", false, "Reinstall & update Venticord")
-	var uninstallFlag = flag.Bool("uninstall", false, "Uninstall Venticord from a Discord install")
-	var installOpenAsar = flag.Bool("install-openasar", false, "Install OpenAsar on a Discord install")
-	var uninstallOpenAsar = flag.Bool("uninstall-openasar", false, "Uninstall OpenAsar from a Discord install")
-	var locationFlag = flag.String("location", "", "Select the location of your Discord install")
-	var branchFlag = flag.String("branch", "", "Select the branch of Discord you want to modify [auto|stable|ptb|canary]")
-	flag.Parse()
-
-	if *locationFlag != "" && *branchFlag != "" {
-		die("The 'location' and 'branch' flags are mutually exclusive.")
+	args := translateLegacyArgs(os.Args[1:])
+	if len(args) == 0 {
+		PrintUsage(os.Stdout)
+		exit(ExitUsage, nil)
 	}
 
-	if !isValidBranch(*branchFlag) {
-		die("The 'branch' flag must be one of the following: [auto|stable|ptb|canary]")
+	if strings.HasPrefix(args[0], "-") {
+		switch strings.TrimLeft(args[0], "-") {
+		case "h", "help":
+			PrintUsage(os.Stdout)
+			exit(ExitOk, nil)
+		default:
+			die("Missing command before '" + args[0] + "'. Run '" + programName + " help' to see all commands.")
+		}
 	}
 
-	if *installFlag || *updateFlag {
-		if !<-GithubDoneChan {
-			die("Not " + Ternary(*installFlag, "installing", "updating") + " as fetching release data failed")
+	if args[0] == "help" {
+		if len(args) > 1 {
+			if cmd, _ := findCommand(args[1:]); cmd != nil {
+				fs := cmd.FlagSet(&cliOptions{})
+				fs.SetOutput(os.Stdout)
+				fs.Usage()
+				exit(ExitOk, nil)
+			}
 		}
+		PrintUsage(os.Stdout)
+		exit(ExitOk, nil)
+	}
+
+	cmd, rest := findCommand(args)
+	if cmd == nil {
+		die("Unknown command '" + args[0] + "'. Run '" + programName + " help' to see all commands.")
 	}
+	cmd.Execute(rest)
+}
+
+// PrintInstalls prints a table of all Discord installs
+func PrintInstalls() {
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	_, _ = fmt.Fprintln(w, "BRANCH\tPATCHED\tVENTICORD\tDISCORD\tFLATPAK\tSYSTEM ELECTRON\tOPENASAR\tPATH")
+	for _, discord := range discords {
+		info := discord.(*DiscordInstall).Info()
+		_, _ = fmt.Fprintln(w, strings.Join([]string{
+			info.Branch,
+			yesNo(info.IsPatched),
+			Ternary(info.VenticordVersion != "", info.VenticordVersion, "-"),
+			Ternary(info.AppVersion != "", info.AppVersion, "-"),
+			yesNo(info.IsFlatpak),
+			yesNo(info.IsSystemElectron),
+			yesNo(info.IsOpenAsar),
+			info.Path,
+		}, "\t"))
+	}
+	_ = w.Flush()
+
+	if len(discords) == 0 {
+		fmt.Println("No Discord installs found")
+	}
+}
 
-	fmt.Println("Venticord Installer CLI", InstallerTag, "("+InstallerGitHash+")")
+func yesNo(b bool) string {
+	return Ternary(b, "yes", "no")
+}
 
-	var err error
-	if *installFlag {
-		_ = PromptDiscord("patch", *locationFlag, *branchFlag).patch()
-	} else if *uninstallFlag {
-		_ = PromptDiscord("unpatch", *locationFlag, *branchFlag).unpatch()
-	} else if *updateFlag {
-		err := installLatestBuilds()
-		if err == nil {
-			PromptDiscord("repatch", *locationFlag, *branchFlag).patch()
+// PromptDiscords picks the installs to act on. Every install if all is set, one per branch,
+// or the one at dir. Without any of them, the user is asked which one to use
+func PromptDiscords(action, dir string, branches []string, all bool) []*DiscordInstall {
+	if all {
+		if len(discords) == 0 {
+			exit(ExitNoInstall, errors.New("No Discord installs found"))
 		}
-	} else if *installOpenAsar {
-		discord := PromptDiscord("patch", *locationFlag, *branchFlag)
-		if !discord.IsOpenAsar() {
-			err = discord.InstallOpenAsar()
-		} else {
-			die("OpenAsar already installed")
+		installs := make([]*DiscordInstall, len(discords))
+		for i, discord := range discords {
+			installs[i] = discord.(*DiscordInstall)
 		}
-	} else if *uninstallOpenAsar {
-		discord := PromptDiscord("patch", *locationFlag, *branchFlag)
-		if discord.IsOpenAsar() {
-			err = discord.UninstallOpenAsar()
-		} else {
-			die("OpenAsar not installed")
+		return installs
+	}
+
+	if len(branches) == 0 {
+		return []*DiscordInstall{PromptDiscord(action, dir, "")}
+	}
+
+	var installs []*DiscordInstall
+	for _, branch := range branches {
+		install := PromptDiscord(action, dir, branch)
+		if !ArrayIncludes(installs, install) {
+			installs = append(installs, install)
 		}
-	} else {
-		flag.Usage()
 	}
+	return installs
+}
 
-	if err != nil {
-		fmt.Println(err)
-		os.Exit(1)
+// runOnInstalls runs fn on every install, prints a summary if there are several and records the results for -json
+func runOnInstalls(installs []*DiscordInstall, action string, fn func(di *DiscordInstall) error) error {
+	results, err := ForEachInstall(installs, action, fn)
+	result.Results = results
+	if len(results) > 1 {
+		fmt.Println("Summary:")
+		fmt.Println(SummarizeResults(results))
 	}
+	return err
 }
 
+// PromptDiscord picks the install to act on from dir or branch, or asks the user which one to use
 func PromptDiscord(action, dir, branch string) *DiscordInstall {
 	if branch == "auto" {
 		for _, b := range []string{"stable", "canary", "ptb"} {
@@ -103,7 +156,7 @@ func PromptDiscord(action, dir, branch string) *DiscordInstall {
 				}
 			}
 		}
-		die("No Discord install found. Try manually specifying it with the --dir flag")
+		exit(ExitNoInstall, errors.New("No Discord install found. Try manually specifying it with the -location flag"))
 	}
 
 	if branch != "" {
@@ -113,17 +166,21 @@ func PromptDiscord(action, dir, branch string) *DiscordInstall {
 				return install
 			}
 		}
-		die("Discord " + branch + " not found")
+		exit(ExitNoInstall, errors.New("Discord "+branch+" not found"))
 	}
 
 	if dir != "" {
 		if discord := ParseDiscord(dir, branch); discord != nil {
 			return discord
 		} else {
-			die(dir + " is not a valid Discord install")
+			exit(ExitNoInstall, errors.New(dir+" is not a valid Discord install"))
 		}
 	}
 
+	if nonInteractive {
+		exit(ExitNoInstall, errors.New("Not asking which Discord install to "+action+" in non-interactive mode. Select one with -location or -branch"))
+	}
+
 	fmt.Println("Please choose a Discord install to", action)
 
 	for i, discord := range discords {
@@ -137,6 +194,9 @@ func PromptDiscord(action, dir, branch string) *DiscordInstall {
 	for {
 		fmt.Printf("> ")
 		if _, err := fmt.Scan(&choice); err != nil {
+			if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+				exit(ExitNoInstall, errors.New("Can't ask which Discord install to "+action+" as stdin is closed. Select one with -location or -branch"))
+			}
 			fmt.Println("That wasn't a valid choice")
 			continue
 		}
@@ -149,10 +209,13 @@ func PromptDiscord(action, dir, branch string) *DiscordInstall {
 		if choice == len(discords) {
 			var custom string
 			fmt.Print("Custom Discord Install: ")
-			if _, err := fmt.Scan(&custom); err == nil {
+			_, err := fmt.Scan(&custom)
+			if err == nil {
 				if discord := ParseDiscord(custom, branch); discord != nil {
 					return discord
 				}
+			} else if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+				exit(ExitNoInstall, errors.New("Can't ask for a custom Discord install as stdin is closed. Select one with -location"))
 			}
 		}
 
@@ -160,13 +223,87 @@ func PromptDiscord(action, dir, branch string) *DiscordInstall {
 	}
 }
 
+// RecoverJournals finishes or undoes patches that were interrupted, asking the user which unless non-interactive.
+// Returns whether there were any
+func RecoverJournals() bool {
+	journals := PendingJournals()
+	for _, j := range journals {
+		LogWarn(j.String())
+
+		var err error
+		if nonInteractive {
+			LogInfo("Undoing it as this is non-interactive")
+			if err = j.RollBack(); err != nil {
+				LogWarn("Failed to undo it, finishing it instead:", err)
+				err = j.RollForward()
+			}
+		} else {
+			fmt.Println("[1] Finish it")
+			fmt.Println("[2] Undo it")
+
+			var choice int
+			for {
+				fmt.Printf("> ")
+				_, err := fmt.Scan(&choice)
+				if err == nil && (choice == 1 || choice == 2) {
+					break
+				}
+				if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+					exit(ExitFailure, errors.New("Can't ask what to do with the interrupted "+j.Operation+" as stdin is closed. Rerun with -non-interactive to undo it"))
+				}
+				fmt.Println("That wasn't a valid choice")
+			}
+			err = Ternary(choice == 1, j.RollForward, j.RollBack)()
+		}
+
+		if err != nil {
+			LogError("Failed to recover "+j.Dir+". You might have to reinstall Discord:", err)
+		} else {
+			LogInfo("Recovered", j.Dir)
+		}
+	}
+	return len(journals) != 0
+}
+
 func InstallLatestBuilds() error {
-	return installLatestBuilds()
+	if IsDevInstall && DevCheckout == "" {
+		// VENCORD_DEV_INSTALL, the files are already in place
+		return nil
+	}
+
+	err := installLatestBuilds()
+	if err != nil {
+		LogError("Failed to install the latest Venticord builds " + Ternary(BundlePath != "", "from "+BundlePath, "from GitHub") + ":\n" + err.Error())
+	}
+	return err
+}
+
+func ReportDownloadProgress(p DownloadProgress) {
+	progressLock.Lock()
+	defer progressLock.Unlock()
+
+	var parts []string
+	allDone := true
+	quiet := ConsoleLevel > LevelInfo
+	for _, d := range downloadTracker.Update(p) {
+		parts = append(parts, d.String())
+		allDone = allDone && d.Done
+	}
+
+	if !quiet {
+		fmt.Print("\r" + strings.Join(parts, " | "))
+	}
+	if allDone {
+		if !quiet {
+			fmt.Println()
+		}
+		downloadTracker.Reset()
+	}
 }
 
 func HandleScuffedInstall() {
-	fmt.Println("Hold On!")
-	fmt.Println("You have a broken Discord Install.")
-	fmt.Println("Please reinstall Discord before proceeding!")
-	fmt.Println("Otherwise, Vencord will likely not work.")
+	LogWarn("Hold On!")
+	LogWarn("You have a broken Discord Install.")
+	LogWarn("Please reinstall Discord before proceeding!")
+	LogWarn("Otherwise, Vencord will likely not work.")
 }
@@ -0,0 +1,633 @@
+//go:build cli
+
+/*
+ * SPDX-License-Identifier: GPL-3.0
+ * Vencord Installer, a cross platform gui/cli app for installing Vencord
+ * Copyright (c) 2023 Vendicated and Vencord contributors
+ */
+
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+)
+
+// Flag groups a command can opt into. Every command gets -json and -non-interactive
+const (
+	// -location, -branch and -all, to select the Discord installs to modify
+	flagsTarget = 1 << iota
+	// -version, to pin a release
+	flagsRelease
+	// -from-bundle, -from-dir and the -dev flags, to install Venticord from disk
+	flagsSource
+	// -proxy, -ca-bundle, -github-token and -release-source
+	flagsNetwork
+	// -dry-run, for commands that modify Discord installs
+	flagsDryRun
+)
+
+var programName = filepath.Base(os.Args[0])
+
+// cliCommand is a subcommand of the CLI like "install" or "openasar uninstall"
+type cliCommand struct {
+	Name string
+	// Positional arguments shown in the usage line, if the command takes any
+	Args        string
+	Description string
+	Flags       int
+	// Fetch the latest (or pinned) release in the background before running. The other commands work offline
+	NeedsRelease bool
+	// Registers flags only this command has
+	ExtraFlags func(fs *flag.FlagSet, o *cliOptions)
+	Run        func(o *cliOptions, args []string) error
+}
+
+// cliOptions holds the values of all flags. Which of them are set depends on the command's flag groups
+type cliOptions struct {
+	location string
+	branches []string
+	all      bool
+
+	version string
+
+	bundle     string
+	dir        string
+	dev        string
+	devSymlink bool
+	devWatch   bool
+
+	proxy          string
+	caBundle       string
+	githubToken    string
+	releaseSources []ReleaseSource
+
+	output string
+
+	dryRun bool
+
+	verbose bool
+	quiet   bool
+}
+
+var commands = []*cliCommand{
+	{
+		Name:         "install",
+		Description:  "Patch Discord installs with Venticord, downloading the latest (or pinned) version first if needed",
+		Flags:        flagsTarget | flagsRelease | flagsSource | flagsNetwork | flagsDryRun,
+		NeedsRelease: true,
+		Run:          runInstall,
+	},
+	{
+		Name:        "uninstall",
+		Description: "Remove Venticord from Discord installs",
+		Flags:       flagsTarget | flagsDryRun,
+		Run:         runUninstall,
+	},
+	{
+		Name:         "repair",
+		Description:  "Reinstall Venticord and patch Discord installs again, even if they already are",
+		Flags:        flagsTarget | flagsRelease | flagsSource | flagsNetwork | flagsDryRun,
+		NeedsRelease: true,
+		Run:          runRepair,
+	},
+	{
+		Name:         "update",
+		Description:  "Install the latest (or pinned) Venticord version. Patched Discord installs use it right away",
+		Flags:        flagsRelease | flagsSource | flagsNetwork,
+		NeedsRelease: true,
+		Run:          runUpdate,
+	},
+	{
+		Name:        "openasar install",
+		Description: "Replace the app.asar of Discord installs with OpenAsar",
+		Flags:       flagsTarget | flagsNetwork | flagsDryRun,
+		Run:         runInstallOpenAsar,
+	},
+	{
+		Name:        "openasar uninstall",
+		Description: "Restore the original app.asar of Discord installs",
+		Flags:       flagsTarget | flagsDryRun,
+		Run:         runUninstallOpenAsar,
+	},
+	{
+		Name:        "list",
+		Description: "List all Discord installs and their state. Combine with -json for machine readable output",
+		Run:         runList,
+	},
+	{
+		Name:        "verify",
+		Description: "Check that the installed Venticord files are intact and that all patched installs load them, and repair what isn't",
+		Flags:       flagsRelease | flagsNetwork,
+		Run:         runVerify,
+	},
+	{
+		Name:        "rollback",
+		Args:        "[hash]",
+		Description: "Switch to a previously installed Venticord version, or the one installed before the current one if no hash is given. The last " + strconv.Itoa(KeptVersions) + " installed versions are kept",
+		Run:         runRollback,
+	},
+	{
+		Name:         "changelog",
+		Description:  "Show the release notes of the latest (or pinned) Venticord version and the commits since your version",
+		Flags:        flagsRelease | flagsNetwork,
+		NeedsRelease: true,
+		Run:          runChangelog,
+	},
+	{
+		Name:        "doctor",
+		Description: "Collect a redacted diagnostic report to attach to bug reports",
+		Flags:       flagsNetwork,
+		ExtraFlags: func(fs *flag.FlagSet, o *cliOptions) {
+			fs.StringVar(&o.output, "o", "", "Save the report to this file instead of printing it. If it ends in .zip, the config, install manifest and logs are included too")
+		},
+		NeedsRelease: true,
+		Run:          runDoctor,
+	},
+}
+
+// findCommand looks up the command args start with and returns it along with the remaining args
+func findCommand(args []string) (*cliCommand, []string) {
+	for _, cmd := range commands {
+		words := strings.Fields(cmd.Name)
+		if len(args) >= len(words) && strings.Join(args[:len(words)], " ") == cmd.Name {
+			return cmd, args[len(words):]
+		}
+	}
+
+	// "openasar" on its own or with an unknown subcommand
+	var subcommands []string
+	for _, cmd := range commands {
+		if parent, sub, ok := strings.Cut(cmd.Name, " "); ok && parent == args[0] {
+			subcommands = append(subcommands, sub)
+		}
+	}
+	if len(subcommands) != 0 {
+		die("Usage: " + programName + " " + args[0] + " <" + strings.Join(subcommands, "|") + "> [flags]")
+	}
+
+	return nil, nil
+}
+
+// PrintUsage prints all commands
+func PrintUsage(out io.Writer) {
+	_, _ = fmt.Fprintln(out, "Usage:", programName, "<command> [flags]")
+	_, _ = fmt.Fprintln(out)
+	_, _ = fmt.Fprintln(out, "Commands:")
+
+	w := tabwriter.NewWriter(out, 0, 0, 3, ' ', 0)
+	for _, cmd := range commands {
+		_, _ = fmt.Fprintln(w, "  "+cmd.Name+"\t"+cmd.Description)
+	}
+	_ = w.Flush()
+
+	_, _ = fmt.Fprintln(out)
+	_, _ = fmt.Fprintln(out, "Run '"+programName+" <command> -h' to see the flags of a command.")
+}
+
+func (c *cliCommand) FlagSet(o *cliOptions) *flag.FlagSet {
+	fs := flag.NewFlagSet(c.Name, flag.ContinueOnError)
+	fs.Usage = func() {
+		out := fs.Output()
+		_, _ = fmt.Fprintln(out, "Usage:", programName, c.Name, "[flags]", c.Args)
+		_, _ = fmt.Fprintln(out)
+		_, _ = fmt.Fprintln(out, c.Description)
+		_, _ = fmt.Fprintln(out)
+		_, _ = fmt.Fprintln(out, "Flags:")
+		fs.PrintDefaults()
+	}
+
+	if c.Flags&flagsTarget != 0 {
+		fs.StringVar(&o.location, "location", "", "Select the location of your Discord install")
+		fs.Func("branch", "Select the branch of Discord you want to modify [auto|stable|ptb|canary]. Can be repeated to modify several", func(s string) error {
+			if s == "" || !isValidBranch(s) {
+				return errors.New("must be one of [auto|stable|ptb|canary]")
+			}
+			o.branches = append(o.branches, s)
+			return nil
+		})
+		fs.BoolVar(&o.all, "all", false, "Modify every Discord install found")
+	}
+
+	if c.Flags&flagsRelease != 0 {
+		fs.StringVar(&o.version, "version", "", "Pin Venticord to the release with this tag instead of always using the latest one. Pass 'latest' to unpin")
+	}
+
+	if c.Flags&flagsSource != 0 {
+		fs.StringVar(&o.bundle, "from-bundle", "", "Install Venticord from a local zip bundle instead of downloading it")
+		fs.StringVar(&o.dir, "from-dir", "", "Install Venticord from a local folder instead of downloading it. Same layout as -from-bundle")
+		fs.StringVar(&o.dev, "dev", "", "Install Venticord from the dist folder of a local Vencord checkout")
+		fs.BoolVar(&o.devSymlink, "dev-symlink", false, "With -dev, symlink the build files in the dist folder instead of copying them")
+		fs.BoolVar(&o.devWatch, "dev-watch", false, "With -dev, keep running and reinstall whenever the dist folder changes")
+	}
+
+	if c.Flags&flagsDryRun != 0 {
+		fs.BoolVar(&o.dryRun, "dry-run", false, "Print what would be renamed, deleted, written, downloaded and run instead of doing it")
+	}
+
+	if c.Flags&flagsNetwork != 0 {
+		fs.StringVar(&o.proxy, "proxy", "", "Send all requests through this proxy instead of the one from HTTP_PROXY/HTTPS_PROXY")
+		fs.StringVar(&o.caBundle, "ca-bundle", "", "Trust the certificates in this PEM file in addition to the system ones")
+		fs.StringVar(&o.githubToken, "github-token", "", "Authenticate to the GitHub API with this token to get a higher rate limit. Defaults to the GITHUB_TOKEN environment variable")
+		fs.Func("release-source", "Fetch releases from this source instead of GitHub. Can be repeated, sources are tried in order. Format: type=url[,timeout=10s][,retries=2], type is one of [github|vencord|mirror]", func(s string) error {
+			source, err := ParseReleaseSource(s)
+			if err == nil {
+				o.releaseSources = append(o.releaseSources, source)
+			}
+			return err
+		})
+	}
+
+	if c.ExtraFlags != nil {
+		c.ExtraFlags(fs, o)
+	}
+
+	fs.BoolVar(&jsonMode, "json", false, "Print the result as JSON to stdout once done and log everything else to stderr. Implies -non-interactive")
+	fs.BoolVar(&nonInteractive, "non-interactive", false, "Never prompt. Fail if -location or -branch don't select a Discord install")
+	fs.BoolVar(&nonInteractive, "yes", false, "Alias for -non-interactive")
+	fs.BoolVar(&o.verbose, "verbose", false, "Also print debug messages. They are always written to the log file")
+	fs.BoolVar(&o.quiet, "quiet", false, "Only print warnings and errors")
+
+	return fs
+}
+
+// Execute parses args as the flags of c, runs it and exits
+func (c *cliCommand) Execute(args []string) {
+	var o cliOptions
+	fs := c.FlagSet(&o)
+	// Errors are reported by die, which also takes care of -json
+	fs.SetOutput(io.Discard)
+	args, err := parseInterspersed(fs, args)
+	if err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			fs.SetOutput(os.Stdout)
+			fs.Usage()
+			exit(ExitOk, nil)
+		}
+		die(err.Error() + "\nRun '" + programName + " " + c.Name + " -h' to see its flags.")
+	}
+
+	if o.verbose && o.quiet {
+		die("The 'verbose' and 'quiet' flags are mutually exclusive.")
+	}
+	if jsonMode {
+		os.Stdout = os.Stderr
+	}
+	ConsoleLevel = Ternary(o.verbose, LevelDebug, Ternary(o.quiet, LevelWarn, LevelInfo))
+	ReleaseConsole()
+
+	result.Action = c.Name
+	nonInteractive = nonInteractive || jsonMode
+
+	if c.Args == "" && len(args) != 0 {
+		die("Unexpected argument '" + args[0] + "'. Run '" + programName + " " + c.Name + " -h' to see its flags.")
+	}
+
+	o.apply()
+	// Only commands that modify installs need them in a consistent state. The others leave interrupted patches
+	// alone, so doctor can still report them
+	if c.Flags&flagsTarget != 0 {
+		if o.dryRun {
+			if len(PendingJournals()) != 0 {
+				LogWarn("Not finishing or undoing interrupted patches during a dry run")
+			}
+		} else if RecoverJournals() {
+			discords = FindDiscords()
+		}
+	}
+	if c.NeedsRelease {
+		InitGithubDownloader()
+	} else {
+		DetectInstalledVersion()
+	}
+
+	LogInfo("Venticord Installer CLI", InstallerTag, "("+InstallerGitHash+")")
+
+	if o.dryRun {
+		err = dryRun(func() error {
+			return c.Run(&o, args)
+		})
+	} else {
+		err = c.Run(&o, args)
+	}
+	if err != nil {
+		exit(ExitCodeFor(err), err)
+	}
+
+	if o.devWatch {
+		WatchCheckout(DevCheckout)
+	}
+	exit(ExitOk, nil)
+}
+
+// parseInterspersed parses args like fs.Parse, but also accepts flags after positional arguments,
+// like "rollback abc1234 -json". Returns the positional arguments
+func parseInterspersed(fs *flag.FlagSet, args []string) ([]string, error) {
+	var positional []string
+	for {
+		if err := fs.Parse(args); err != nil {
+			return nil, err
+		}
+
+		rest := fs.Args()
+		// Everything after "--" is positional
+		if consumed := len(args) - len(rest); len(rest) == 0 || (consumed > 0 && args[consumed-1] == "--") {
+			return append(positional, rest...), nil
+		}
+		positional = append(positional, rest[0])
+		args = rest[1:]
+	}
+}
+
+// apply validates the flags and configures everything that has to be set up before InitGithubDownloader
+func (o *cliOptions) apply() {
+	if (o.location != "" && len(o.branches) != 0) || (o.all && (o.location != "" || len(o.branches) != 0)) {
+		die("The 'location', 'branch' and 'all' flags are mutually exclusive.")
+	}
+
+	if len(o.releaseSources) != 0 {
+		ReleaseSources = o.releaseSources
+		ConfigSourcesErr = nil
+	}
+
+	if o.proxy != "" || o.caBundle != "" {
+		proxy := Ternary(o.proxy != "", o.proxy, InstallerConfig.Proxy)
+		caBundle := Ternary(o.caBundle != "", o.caBundle, InstallerConfig.CaBundle)
+		if err := ConfigureHttpClient(proxy, caBundle); err != nil {
+			die(err.Error())
+		}
+		// What's still taken from the config file was valid, or this would have failed. Unless the file couldn't
+		// be parsed, then only flags replacing both settings make it irrelevant
+		if !ConfigUnreadable || (o.proxy != "" && o.caBundle != "") {
+			ConfigNetworkErr = nil
+		}
+	}
+
+	if o.githubToken != "" {
+		GithubToken = o.githubToken
+	}
+
+	if o.bundle != "" && o.dir != "" {
+		die("The 'from-bundle' and 'from-dir' flags are mutually exclusive.")
+	}
+	BundlePath = o.bundle + o.dir
+
+	if o.dev != "" {
+		if BundlePath != "" {
+			die("The 'dev' flag can't be used together with 'from-bundle' or 'from-dir'.")
+		}
+		DevCheckout = o.dev
+		DevSymlink = o.devSymlink
+		IsDevInstall = true
+	} else if o.devSymlink || o.devWatch {
+		die("The 'dev-symlink' and 'dev-watch' flags require the 'dev' flag.")
+	}
+	if o.devWatch && o.dryRun {
+		die("The 'dev-watch' and 'dry-run' flags are mutually exclusive.")
+	}
+	ReadOnlyCache = o.dryRun
+
+	if o.version != "" && o.dryRun {
+		// Only for this run, pinning would write to disk
+		PinnedTag = Ternary(o.version == "latest", "", strings.TrimSpace(o.version))
+	} else if o.version != "" {
+		if err := PinRelease(o.version); err != nil {
+			die("Failed to pin version: " + err.Error())
+		}
+	}
+}
+
+// dryRun runs fn against a virtual filesystem and prints what it would have changed
+func dryRun(fn func() error) error {
+	plan, err := DryRun(fn)
+	result.Plan = plan.Plan
+	// In -json mode, exit prints it already
+	if !jsonMode {
+		fmt.Println()
+		fmt.Println("Dry run, nothing was changed. This is what would have been done:")
+		fmt.Print(plan.String())
+	}
+	return err
+}
+
+func (o *cliOptions) promptDiscords(action string) []*DiscordInstall {
+	return PromptDiscords(action, o.location, o.branches, o.all)
+}
+
+// waitForRelease exits if fetching the release data failed. doing describes the command, like "installing"
+func waitForRelease(doing string) {
+	if !<-GithubDoneChan {
+		exit(Ternary(ConfigFetchErr() != nil, ExitFailure, ExitNetwork), errors.New("Not "+doing+" as fetching release data failed: "+GithubError.Error()))
+	}
+}
+
+func runInstall(o *cliOptions, _ []string) error {
+	waitForRelease("installing")
+
+	installs := o.promptDiscords("patch")
+	// patch() only logs this as the GUI shows its own dialog
+	if LatestHash != InstalledHash {
+		if err := InstallLatestBuilds(); err != nil {
+			return err
+		}
+	}
+	return runOnInstalls(installs, "patch", (*DiscordInstall).patch)
+}
+
+func runUninstall(o *cliOptions, _ []string) error {
+	return runOnInstalls(o.promptDiscords("unpatch"), "unpatch", (*DiscordInstall).unpatch)
+}
+
+func runRepair(o *cliOptions, _ []string) error {
+	waitForRelease("repairing")
+
+	installs := o.promptDiscords("repatch")
+	if err := InstallLatestBuilds(); err != nil {
+		return err
+	}
+	return runOnInstalls(installs, "repatch", (*DiscordInstall).patch)
+}
+
+func runUpdate(_ *cliOptions, _ []string) error {
+	waitForRelease("updating")
+
+	if BundlePath == "" && DevCheckout == "" && LatestHash == InstalledHash {
+		LogInfo("Venticord", InstalledHash, "is already up to date")
+		return nil
+	}
+	return InstallLatestBuilds()
+}
+
+func runInstallOpenAsar(o *cliOptions, _ []string) error {
+	return runOnInstalls(o.promptDiscords("install OpenAsar on"), "install OpenAsar on", func(discord *DiscordInstall) error {
+		if discord.IsOpenAsar() {
+			return errors.New("OpenAsar already installed")
+		}
+		return discord.InstallOpenAsar()
+	})
+}
+
+func runUninstallOpenAsar(o *cliOptions, _ []string) error {
+	return runOnInstalls(o.promptDiscords("uninstall OpenAsar from"), "uninstall OpenAsar from", func(discord *DiscordInstall) error {
+		if !discord.IsOpenAsar() {
+			return errors.New("OpenAsar not installed")
+		}
+		return discord.UninstallOpenAsar()
+	})
+}
+
+func runList(_ *cliOptions, _ []string) error {
+	// In -json mode, exit prints everything already
+	if !jsonMode {
+		PrintInstalls()
+	}
+	return nil
+}
+
+func runVerify(_ *cliOptions, _ []string) error {
+	report := CheckIntegrity()
+	result.Integrity = report
+	// In -json mode, exit prints it already
+	if !jsonMode {
+		fmt.Println(report.String())
+	}
+	if report.IsOk() {
+		return nil
+	}
+
+	if _, err := RepairIntegrity(report); err != nil {
+		return err
+	}
+	LogInfo("Repaired everything!")
+	return nil
+}
+
+func runRollback(_ *cliOptions, args []string) error {
+	if len(args) > 1 {
+		die("rollback takes at most one hash")
+	}
+
+	var hash string
+	if len(args) == 1 {
+		hash = args[0]
+	}
+	if err := RollbackVersion(hash); err != nil {
+		return err
+	}
+	LogInfo("Now using Venticord", InstalledHash)
+	return nil
+}
+
+func runChangelog(_ *cliOptions, _ []string) error {
+	waitForRelease("showing the changelog")
+
+	result.Changelog = GetChangelog()
+	// In -json mode, exit prints it already
+	if !jsonMode {
+		fmt.Println()
+		fmt.Print(result.Changelog)
+	}
+	return nil
+}
+
+func runDoctor(o *cliOptions, _ []string) error {
+	// The report includes the latest version, or why fetching it failed
+	<-GithubDoneChan
+
+	report := RunDoctor()
+	result.Report = report.String()
+	if o.output == "" {
+		// In -json mode, exit prints it already
+		if !jsonMode {
+			fmt.Println()
+			fmt.Print(result.Report)
+		}
+		return nil
+	}
+
+	if err := report.Write(o.output); err != nil {
+		return err
+	}
+	LogInfo("Saved doctor report to", o.output)
+	return nil
+}
+
+// legacyActions maps the flags the CLI used before it had commands to the command replacing them
+var legacyActions = map[string]string{
+	"install":            "install",
+	"reinstall":          "repair",
+	"uninstall":          "uninstall",
+	"install-openasar":   "openasar install",
+	"uninstall-openasar": "openasar uninstall",
+	"list":               "list",
+	"verify":             "verify",
+	"rollback":           "rollback",
+	"changelog":          "changelog",
+}
+
+// translateLegacyArgs turns the old flag style invocation, like "-install -branch stable" which install.ps1 still uses,
+// into "install -branch stable". Args that already start with a command are returned as is
+func translateLegacyArgs(args []string) []string {
+	if len(args) == 0 || !strings.HasPrefix(args[0], "-") {
+		return args
+	}
+
+	var legacyFlags, rest []string
+	var command, hash string
+	for i, arg := range args {
+		if arg == "--" {
+			rest = append(rest, args[i:]...)
+			break
+		}
+
+		name, value, hasValue := strings.Cut(strings.TrimLeft(arg, "-"), "=")
+		cmd, ok := legacyActions[name]
+		if !ok || !strings.HasPrefix(arg, "-") {
+			rest = append(rest, arg)
+			continue
+		}
+		if hasValue && value != "true" {
+			if name != "rollback" || value == "false" {
+				// -install=false
+				continue
+			}
+			// -rollback=<hash>
+			hash = value
+		}
+
+		legacyFlags = append(legacyFlags, "-"+name)
+		command = cmd
+	}
+	if hash != "" {
+		rest = append([]string{hash}, rest...)
+	}
+
+	if len(legacyFlags) > 1 {
+		die("The '" + strings.Join(legacyFlags[:len(legacyFlags)-1], "', '") + "' and '" + legacyFlags[len(legacyFlags)-1] + "' flags are mutually exclusive.")
+	}
+
+	if command == "" {
+		// Installing from disk without patching used to be just -from-bundle, -from-dir or -dev
+		for _, arg := range rest {
+			name, _, _ := strings.Cut(strings.TrimLeft(arg, "-"), "=")
+			if name == "from-bundle" || name == "from-dir" || name == "dev" {
+				command = "update"
+				break
+			}
+		}
+		if command == "" {
+			return args
+		}
+	} else {
+		LogWarn("Note: the '" + legacyFlags[0] + "' flag is deprecated, use '" + programName + " " + command + "' instead")
+	}
+
+	return append(strings.Fields(command), rest...)
+}
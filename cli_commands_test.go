@@ -0,0 +1,61 @@
+//go:build cli
+
+/*
+ * SPDX-License-Identifier: GPL-3.0
+ * Vencord Installer, a cross platform gui/cli app for installing Vencord
+ * Copyright (c) 2023 Vendicated and Vencord contributors
+ */
+
+package main
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestTranslateLegacyArgs(t *testing.T) {
+	tests := map[string]string{
+		"-install -branch stable": "install -branch stable",
+		"-install=false -list":    "list",
+		"-rollback":               "rollback",
+		"-rollback b5 -quiet":     "rollback b5 -quiet",
+		"-rollback=b5 -quiet":     "rollback b5 -quiet",
+		"-rollback=true":          "rollback",
+		"-from-dir build":         "update -from-dir build",
+		"rollback b5":             "rollback b5",
+	}
+
+	for args, want := range tests {
+		if got := strings.Join(translateLegacyArgs(strings.Fields(args)), " "); got != want {
+			t.Errorf("%s: expected '%s', got '%s'", args, want, got)
+		}
+	}
+}
+
+func TestParseFlagsAfterArguments(t *testing.T) {
+	cmd, _ := findCommand([]string{"rollback"})
+	tests := []struct {
+		args     string
+		want     []string
+		wantJson bool
+	}{
+		{"b5", []string{"b5"}, false},
+		{"b5 -json", []string{"b5"}, true},
+		{"-json b5", []string{"b5"}, true},
+		{"-- -json", []string{"-json"}, false},
+		{"b5 -- -json", []string{"b5", "-json"}, false},
+	}
+
+	for _, test := range tests {
+		jsonMode = false
+		args, err := parseInterspersed(cmd.FlagSet(&cliOptions{}), strings.Fields(test.args))
+		if err != nil {
+			t.Fatal(test.args+":", err)
+		}
+		if !reflect.DeepEqual(args, test.want) || jsonMode != test.wantJson {
+			t.Errorf("%s: expected %v with -json %v, got %v with -json %v", test.args, test.want, test.wantJson, args, jsonMode)
+		}
+	}
+	jsonMode = false
+}
@@ -0,0 +1,108 @@
+//go:build cli
+
+/*
+ * SPDX-License-Identifier: GPL-3.0
+ * Vencord Installer, a cross platform gui/cli app for installing Vencord
+ * Copyright (c) 2023 Vendicated and Vencord contributors
+ */
+
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"net"
+	"os"
+)
+
+// Exit codes of the CLI, so scripts can tell failures apart
+const (
+	ExitOk = 0
+	// Anything not covered by the other codes
+	ExitFailure = 1
+	// Invalid flags
+	ExitUsage       = 2
+	ExitNoInstall   = 3
+	ExitPermission  = 4
+	ExitNetwork     = 5
+	ExitDiscordBusy = 6
+)
+
+// CliResult is printed to stdout in -json mode once the CLI is done
+type CliResult struct {
+	Action string `json:"action"`
+	// Every Discord install that was found
+	Installs []InstallInfo `json:"installs"`
+	// Outcome per install the action was performed on, if any
+	Results []InstallResult `json:"results,omitempty"`
+	// The doctor report, if that was the action
+	Report string `json:"report,omitempty"`
+	// What verify found before repairing it, if that was the action
+	Integrity *IntegrityReport `json:"integrity,omitempty"`
+	// The changelog as markdown, if that was the action
+	Changelog string `json:"changelog,omitempty"`
+	// What a -dry-run would have changed, in order
+	Plan     []string `json:"plan,omitempty"`
+	Version  string   `json:"version"`
+	Ok       bool     `json:"ok"`
+	Error    string   `json:"error,omitempty"`
+	ExitCode int      `json:"exitCode"`
+}
+
+var result CliResult
+var jsonMode bool
+
+// resultOutput is where -json results go. Everything else is logged to stderr instead in -json mode
+var resultOutput = os.Stdout
+
+// Nothing is printed until Execute parsed -json, -verbose and -quiet. This is a variable initializer rather
+// than part of main so it happens before any init function logs something
+var _ = HoldConsole()
+
+// ExitCodeFor maps err to one of the exit codes
+func ExitCodeFor(err error) int {
+	var assetErr *AssetError
+	var statusErr *HttpStatusError
+	var netErr net.Error
+	switch {
+	case err == nil:
+		return ExitOk
+	case errors.Is(err, ErrDiscordBusy):
+		return ExitDiscordBusy
+	case errors.Is(err, os.ErrPermission):
+		return ExitPermission
+	case errors.As(err, &assetErr) && assetErr.Kind != AssetErrorDisk && assetErr.Kind != AssetErrorChecksum && assetErr.Kind != AssetErrorSize,
+		errors.As(err, &statusErr), errors.As(err, &netErr), errors.Is(err, ErrShortRead):
+		return ExitNetwork
+	default:
+		return ExitFailure
+	}
+}
+
+// exit prints err, and in -json mode the result, then exits with code
+func exit(code int, err error) {
+	if err != nil {
+		LogError(err)
+	}
+	// In case the flags weren't parsed
+	ReleaseConsole()
+
+	if jsonMode {
+		result.Installs = make([]InstallInfo, len(discords))
+		for i, discord := range discords {
+			result.Installs[i] = discord.(*DiscordInstall).Info()
+		}
+		result.Version = InstalledHash
+		result.Ok = err == nil
+		if err != nil {
+			result.Error = err.Error()
+		}
+		result.ExitCode = code
+
+		enc := json.NewEncoder(resultOutput)
+		enc.SetIndent("", "\t")
+		_ = enc.Encode(result)
+	}
+
+	os.Exit(code)
+}
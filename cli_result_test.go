@@ -0,0 +1,36 @@
+//go:build cli
+
+/*
+ * SPDX-License-Identifier: GPL-3.0
+ * Vencord Installer, a cross platform gui/cli app for installing Vencord
+ * Copyright (c) 2023 Vendicated and Vencord contributors
+ */
+
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"testing"
+)
+
+func TestExitCodeForAssetErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want int
+	}{
+		{"network", AssetErrors{{"renderer.js", AssetErrorNetwork, errors.New("connection reset")}}, ExitNetwork},
+		{"short read", AssetErrors{{"renderer.js", AssetErrorShortRead, ErrShortRead}}, ExitNetwork},
+		{"permission", AssetErrors{{"renderer.js", AssetErrorDisk, &os.PathError{Op: "open", Path: "renderer.js", Err: os.ErrPermission}}}, ExitPermission},
+		{"checksum", AssetErrors{{"renderer.js", AssetErrorChecksum, errors.New("mismatch")}}, ExitFailure},
+		{"wrapped", fmt.Errorf("update: %w", AssetErrors{{"renderer.js", AssetErrorHttpStatus, errors.New("404 Not Found")}}), ExitNetwork},
+	}
+
+	for _, test := range tests {
+		if got := ExitCodeFor(test.err); got != test.want {
+			t.Errorf("%s: expected exit code %d, got %d", test.name, test.want, got)
+		}
+	}
+}
@@ -0,0 +1,84 @@
+/*
+ * SPDX-License-Identifier: GPL-3.0
+ * Vencord Installer, a cross platform gui/cli app for installing Vencord
+ * Copyright (c) 2023 Vendicated and Vencord contributors
+ */
+
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+)
+
+// Config is read from ConfigFile in BaseDir. Every field is optional
+type Config struct {
+	// Where to look for Venticord releases, tried in order. Defaults to DefaultReleaseSources
+	ReleaseSources []ReleaseSource `json:"releaseSources,omitempty"`
+	// Proxy url to use instead of HTTP_PROXY and HTTPS_PROXY
+	Proxy string `json:"proxy,omitempty"`
+	// PEM file with additional certificates to trust
+	CaBundle string `json:"caBundle,omitempty"`
+}
+
+var InstallerConfig Config
+var ConfigFile string
+var ConfigErr error
+
+// ConfigSourcesErr and ConfigNetworkErr are set to ConfigErr if it affects the release sources or the network
+// settings, so flags replacing those settings can clear them
+var ConfigSourcesErr, ConfigNetworkErr error
+
+// ConfigUnreadable is set if ConfigFile couldn't be read or parsed, so none of its settings are known
+var ConfigUnreadable bool
+
+func LoadConfig() {
+	b, err := os.ReadFile(ConfigFile)
+	if err != nil {
+		if !errors.Is(err, os.ErrNotExist) {
+			LogWarn("Failed to read", ConfigFile+":", err)
+			setUnreadableConfig(err)
+		}
+		return
+	}
+
+	LogInfo("Loading config from", ConfigFile)
+	if err = json.Unmarshal(b, &InstallerConfig); err != nil {
+		LogWarn("Failed to parse", ConfigFile+":", err)
+		setUnreadableConfig(errors.New("Failed to parse " + ConfigFile + ": " + err.Error()))
+		return
+	}
+
+	for i := range InstallerConfig.ReleaseSources {
+		if err = InstallerConfig.ReleaseSources[i].Validate(); err != nil {
+			ConfigErr = errors.New("Invalid release source in " + ConfigFile + ": " + err.Error())
+			ConfigSourcesErr = ConfigErr
+			LogWarn(ConfigErr)
+			break
+		}
+	}
+	if ConfigSourcesErr == nil && len(InstallerConfig.ReleaseSources) != 0 {
+		ReleaseSources = InstallerConfig.ReleaseSources
+	}
+
+	if err = ConfigureHttpClient(InstallerConfig.Proxy, InstallerConfig.CaBundle); err != nil {
+		ConfigErr = errors.New("Invalid network settings in " + ConfigFile + ": " + err.Error())
+		ConfigNetworkErr = ConfigErr
+		LogWarn(ConfigErr)
+	}
+}
+
+func setUnreadableConfig(err error) {
+	ConfigErr, ConfigSourcesErr, ConfigNetworkErr = err, err, err
+	ConfigUnreadable = true
+}
+
+// ConfigFetchErr returns the problem with the config file that keeps releases from being fetched, if any.
+// Settings replaced by flags don't count
+func ConfigFetchErr() error {
+	if ConfigSourcesErr != nil {
+		return ConfigSourcesErr
+	}
+	return ConfigNetworkErr
+}
@@ -18,6 +18,16 @@ const ReleaseUrlFallback = "https://vencord.dev/releases/vencord"
 const InstallerReleaseUrl = "https://api.github.com/repos/Vencord/Installer/releases/latest"
 const InstallerReleaseUrlFallback = "https://vencord.dev/releases/installer"
 
+// ChecksumsAssetName is the sha256sum manifest published with releases that don't embed asset digests
+const ChecksumsAssetName = "checksums.txt"
+
+// AssetManifestName is the optional AssetManifest published with releases
+const AssetManifestName = "assets.json"
+
+// DistFiles are the files that make up a complete Venticord build. Releases can't opt out of these, as
+// index.js loads patcher.js, which in turn loads the others
+var DistFiles = []string{"patcher.js", "preload.js", "renderer.js", "renderer.css"}
+
 var UserAgent = "VenticordInstaller/" + InstallerGitHash + " (https://github.com/Venticord/Installer)"
 
 var (
@@ -0,0 +1,211 @@
+/*
+ * SPDX-License-Identifier: GPL-3.0
+ * Vencord Installer, a cross platform gui/cli app for installing Vencord
+ * Copyright (c) 2023 Vendicated and Vencord contributors
+ */
+
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	path "path/filepath"
+	"strings"
+	"time"
+)
+
+// DevCheckout is a local Vencord checkout whose dist folder is installed instead of downloading releases
+var DevCheckout string
+
+// DevSymlink links the build files of DevCheckout instead of copying them, so rebuilds apply without reinstalling
+var DevSymlink bool
+
+// GetCheckoutHash returns the short hash of the commit checked out in repo
+func GetCheckoutHash(repo string) (string, error) {
+	out, err := exec.Command("git", "-C", repo, "rev-parse", "--short", "HEAD").Output()
+	if err == nil {
+		return strings.TrimSpace(string(out)), nil
+	}
+
+	// git might not be installed, or refuse to work because we're root and the repo belongs to the user
+	LogWarn("Failed to run git, reading .git/HEAD instead:", err)
+	head, err := os.ReadFile(path.Join(repo, ".git", "HEAD"))
+	if err != nil {
+		return "", errors.New(repo + " doesn't look like a git checkout: " + err.Error())
+	}
+
+	hash := strings.TrimSpace(string(head))
+	if strings.HasPrefix(hash, "ref: ") {
+		ref := strings.TrimPrefix(hash, "ref: ")
+		b, err := os.ReadFile(path.Join(repo, ".git", path.FromSlash(ref)))
+		if err != nil {
+			return "", errors.New("Failed to resolve " + ref + ": " + err.Error())
+		}
+		hash = strings.TrimSpace(string(b))
+	}
+
+	if len(hash) > 7 {
+		hash = hash[:7]
+	}
+	return hash, nil
+}
+
+// InstallFromCheckout installs the dist folder of the Vencord checkout at repo as version "dev-<hash>"
+func InstallFromCheckout(repo string) (retErr error) {
+	LogInfo("Installing from local checkout", repo)
+
+	distDir := path.Join(repo, "dist")
+	if !IsDirectory(distDir) {
+		return errors.New(distDir + " does not exist. Build Vencord first")
+	}
+
+	hash, err := GetCheckoutHash(repo)
+	if err != nil {
+		return err
+	}
+	version := "dev-" + hash
+
+	if plan := dryRunPlan(); plan != nil {
+		plan.PlanInstall(version, distDir, nil)
+		LatestHash = version
+		return nil
+	}
+
+	if DevSymlink {
+		if err = linkCheckout(distDir, version); err != nil {
+			return err
+		}
+	} else {
+		if err = copyCheckout(distDir, version); err != nil {
+			return err
+		}
+	}
+
+	// Nothing to download, the checkout is what we want
+	LatestHash = version
+	LogInfo("Done!")
+	return nil
+}
+
+func copyCheckout(distDir, version string) (retErr error) {
+	stagingDir, err := prepareStaging(version)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if retErr != nil {
+			_ = os.RemoveAll(stagingDir)
+		}
+	}()
+
+	entries, err := os.ReadDir(distDir)
+	if err != nil {
+		return err
+	}
+
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || !IsDistFile(name) {
+			continue
+		}
+
+		LogInfo("Copying", name)
+		if _, err = copyBundleFile(os.DirFS(distDir), name, path.Join(stagingDir, name)); err != nil {
+			return errors.New("Failed to copy " + name + ": " + err.Error())
+		}
+	}
+
+	source, _ := path.Abs(distDir)
+	return commitStaging(stagingDir, version, source)
+}
+
+// linkCheckout installs version with symlinks to the build files in distDir, so rebuilds apply without
+// reinstalling. Everything else, like the package.json, lives in the version folder instead of the checkout
+func linkCheckout(distDir, version string) (retErr error) {
+	for _, file := range DistFiles {
+		if !ExistsFile(path.Join(distDir, file)) {
+			return errors.New(distDir + " is missing " + file + ". Build Vencord first")
+		}
+	}
+
+	absDistDir, err := path.Abs(distDir)
+	if err != nil {
+		return err
+	}
+
+	stagingDir, err := prepareStaging(version)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if retErr != nil {
+			_ = os.RemoveAll(stagingDir)
+		}
+	}()
+
+	entries, err := os.ReadDir(absDistDir)
+	if err != nil {
+		return err
+	}
+
+	LogInfo("Linking the build files in", absDistDir)
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || !IsDistFile(name) {
+			continue
+		}
+
+		if err = os.Symlink(path.Join(absDistDir, name), path.Join(stagingDir, name)); err != nil {
+			return err
+		}
+	}
+
+	return commitStaging(stagingDir, version, absDistDir)
+}
+
+// WatchCheckout polls the dist folder of repo and reinstalls it whenever a build file changes. It never returns
+func WatchCheckout(repo string) {
+	distDir := path.Join(repo, "dist")
+	LogInfo("Watching", distDir, "for changes. Press Ctrl+C to stop")
+
+	last := distSnapshot(distDir)
+	for {
+		time.Sleep(time.Second)
+
+		current := distSnapshot(distDir)
+		if current == last {
+			continue
+		}
+		last = current
+
+		// Give the build a moment to finish writing all files
+		time.Sleep(500 * time.Millisecond)
+		LogInfo("Change detected, reinstalling")
+		if err := InstallFromCheckout(repo); err != nil {
+			LogWarn("Failed to reinstall:", err)
+		}
+	}
+}
+
+// distSnapshot describes the build files in distDir by name, size and modification time
+func distSnapshot(distDir string) string {
+	entries, err := os.ReadDir(distDir)
+	if err != nil {
+		return ""
+	}
+
+	var sb strings.Builder
+	for _, entry := range entries {
+		if !IsDistFile(entry.Name()) {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil {
+			continue
+		}
+		_, _ = fmt.Fprintf(&sb, "%s:%d:%d;", entry.Name(), info.Size(), info.ModTime().UnixNano())
+	}
+	return sb.String()
+}
@@ -0,0 +1,383 @@
+/*
+ * SPDX-License-Identifier: GPL-3.0
+ * Vencord Installer, a cross platform gui/cli app for installing Vencord
+ * Copyright (c) 2023 Vendicated and Vencord contributors
+ */
+
+package main
+
+import (
+	"archive/zip"
+	"context"
+	"fmt"
+	"net/url"
+	"os"
+	"os/user"
+	path "path/filepath"
+	"regexp"
+	"runtime"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// DoctorCheckTimeout is how long each reachability check may take
+	DoctorCheckTimeout = 10 * time.Second
+	// maxLayoutEntries limits how many files per folder are listed in the asar layout
+	maxLayoutEntries = 50
+)
+
+// DoctorReport is everything we usually need to know to figure out why patching fails, for users to attach to bug reports
+type DoctorReport struct {
+	CreatedAt time.Time
+	Sections  []*DoctorSection
+}
+
+type DoctorSection struct {
+	Title string
+	Lines []string
+}
+
+func (s *DoctorSection) Add(key string, value any) {
+	s.Lines = append(s.Lines, fmt.Sprintf("%s: %v", key, value))
+}
+
+func (s *DoctorSection) AddLines(prefix string, lines ...string) {
+	for _, line := range lines {
+		s.Lines = append(s.Lines, prefix+line)
+	}
+}
+
+// RunDoctor collects the report. This checks whether release sources are reachable, so it can take a few seconds
+func RunDoctor() *DoctorReport {
+	LogInfo("Collecting doctor report...")
+
+	return &DoctorReport{
+		CreatedAt: time.Now(),
+		Sections: []*DoctorSection{
+			doctorInstaller(),
+			doctorDataDir(),
+			doctorVenticord(),
+			doctorInstalls(),
+			doctorNetwork(),
+		},
+	}
+}
+
+func doctorInstaller() *DoctorSection {
+	s := &DoctorSection{Title: "Installer"}
+	s.Add("Version", InstallerTag+" ("+InstallerGitHash+")")
+	s.Add("Outdated", IsInstallerOutdated)
+	s.Add("Platform", runtime.GOOS+"/"+runtime.GOARCH)
+	s.Add("Go", runtime.Version())
+	s.Add("Uid", os.Getuid())
+	s.Add("Euid", os.Geteuid())
+	s.Add("SUDO_USER", os.Getenv("SUDO_USER"))
+	s.Add("DOAS_USER", os.Getenv("DOAS_USER"))
+	s.Add("HOME", os.Getenv("HOME"))
+	return s
+}
+
+func doctorDataDir() *DoctorSection {
+	s := &DoctorSection{Title: "Data folder"}
+	s.Add("VENCORD_USER_DATA_DIR", os.Getenv("VENCORD_USER_DATA_DIR"))
+	s.Add("DISCORD_USER_DATA_DIR", os.Getenv("DISCORD_USER_DATA_DIR"))
+	s.Add("VENCORD_DEV_INSTALL", os.Getenv("VENCORD_DEV_INSTALL"))
+	s.Add("Resolved from", BaseDirSource)
+	s.Add("BaseDir", BaseDir)
+	s.Add("FilesDir", FilesDir)
+	if FilesDirErr != nil {
+		s.Add("FilesDir error", FilesDirErr)
+	}
+	s.Add("Patcher", Patcher)
+	s.Add("Config", Ternary(ConfigErr != nil, fmt.Sprint(ConfigErr), Ternary(ExistsFile(ConfigFile), "loaded", "none")))
+	return s
+}
+
+func doctorVenticord() *DoctorSection {
+	s := &DoctorSection{Title: "Venticord"}
+	s.Add("Installed version", InstalledHash)
+	if InstalledManifest != nil {
+		s.Add("Installed from", InstalledManifest.Source)
+		s.Add("Installed at", InstalledManifest.InstalledAt.Format(time.RFC3339))
+		s.Add("Installed by", InstalledManifest.InstallerVersion)
+	}
+	s.Add("Active version", ReadActiveVersion())
+	s.Add("Installed versions", strings.Join(InstalledVersions(), ", "))
+	s.Add("Pinned tag", PinnedTag)
+	s.Add("Dev install", IsDevInstall)
+	if DevCheckout != "" {
+		s.Add("Dev checkout", DevCheckout)
+	}
+	if BundlePath != "" {
+		s.Add("Bundle", BundlePath)
+	}
+
+	if GithubError != nil {
+		s.Add("Latest version", "unknown, "+GithubError.Error())
+	} else {
+		s.Add("Latest version", LatestHash+" ("+ReleaseData.TagName+" from "+ReleaseData.SourceUrl+Ternary(ReleaseData.IsStale, ", cached "+FormatAge(ReleaseData.FetchedAt), "")+")")
+	}
+
+	s.AddLines("", strings.Split(CheckIntegrity().String(), "\n")...)
+	return s
+}
+
+func doctorInstalls() *DoctorSection {
+	s := &DoctorSection{Title: "Discord installs"}
+	if len(discords) == 0 {
+		s.Lines = append(s.Lines, "None found")
+	}
+	for _, j := range PendingJournals() {
+		s.Lines = append(s.Lines, j.String())
+	}
+
+	for _, discord := range discords {
+		di := discord.(*DiscordInstall)
+		info := di.Info()
+
+		s.Lines = append(s.Lines, "")
+		s.Add("Path", info.Path)
+		s.Add("  Branch", info.Branch)
+		s.Add("  Patched", info.IsPatched)
+		s.Add("  Flatpak", info.IsFlatpak)
+		s.Add("  System Electron", info.IsSystemElectron)
+		s.Add("  OpenAsar", info.IsOpenAsar)
+		s.Add("  Discord version", info.AppVersion)
+		s.Add("  Venticord version", info.VenticordVersion)
+		s.Add("  App path", di.appPath)
+		s.Add("  Patch folder", di.patchDir())
+
+		resources := Ternary(di.isSystemElectron, di.path, path.Join(di.appPath, ".."))
+		s.Lines = append(s.Lines, "  Layout of "+resources+":")
+		s.AddLines("    ", asarLayout(resources)...)
+
+		if di.isFlatpak {
+			out, err := di.flatpakCommand("override", "--show", di.flatpakId()).CombinedOutput()
+			s.Lines = append(s.Lines, "  Flatpak overrides:")
+			if err != nil {
+				s.AddLines("    ", "Failed to show overrides: "+err.Error())
+			}
+			s.AddLines("    ", strings.Split(strings.TrimSpace(string(out)), "\n")...)
+		}
+	}
+	return s
+}
+
+// asarLayout lists the asar files and patch folders in dir, along with the files inside those folders
+func asarLayout(dir string) []string {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return []string{err.Error()}
+	}
+
+	var lines []string
+	for i, entry := range entries {
+		if i == maxLayoutEntries {
+			lines = append(lines, fmt.Sprintf("... %d more", len(entries)-i))
+			break
+		}
+
+		name := entry.Name()
+		lines = append(lines, describeEntry(path.Join(dir, name)))
+		if !entry.IsDir() || !strings.Contains(name, "app") {
+			continue
+		}
+
+		children, err := os.ReadDir(path.Join(dir, name))
+		if err != nil {
+			lines = append(lines, "  "+err.Error())
+			continue
+		}
+		for j, child := range children {
+			if j == maxLayoutEntries {
+				lines = append(lines, fmt.Sprintf("  ... %d more", len(children)-j))
+				break
+			}
+			lines = append(lines, "  "+describeEntry(path.Join(dir, name, child.Name())))
+		}
+
+		// Shows what the patch loads
+		if b, err := os.ReadFile(path.Join(dir, name, "index.js")); err == nil && len(b) < 1024 {
+			lines = append(lines, "  index.js: "+strings.TrimSpace(string(b)))
+		}
+	}
+	return lines
+}
+
+func describeEntry(p string) string {
+	name := path.Base(p)
+	info, err := os.Lstat(p)
+	switch {
+	case err != nil:
+		return name + " (" + err.Error() + ")"
+	case info.Mode()&os.ModeSymlink != 0:
+		target, _ := os.Readlink(p)
+		return name + " -> " + target
+	case info.IsDir():
+		return name + "/"
+	default:
+		return name + " (" + FormatBytes(info.Size()) + ")"
+	}
+}
+
+func doctorNetwork() *DoctorSection {
+	s := &DoctorSection{Title: "Network"}
+	s.Add("Proxy", Ternary(InstallerConfig.Proxy != "", InstallerConfig.Proxy, os.Getenv("HTTPS_PROXY")))
+	s.Add("NO_PROXY", os.Getenv("NO_PROXY"))
+	s.Add("CA bundle", InstallerConfig.CaBundle)
+	s.Add("GitHub token", GithubToken != "")
+
+	urls := []string{InstallerReleaseUrl, OpenAsarDownloadLink}
+	for _, source := range ReleaseSources {
+		urls = append(urls, source.Url)
+	}
+
+	results := make([]string, len(urls))
+	var wg sync.WaitGroup
+	for i, u := range urls {
+		wg.Add(1)
+		go func(i int, u string) {
+			defer wg.Done()
+			results[i] = u + ": " + checkReachable(u)
+		}(i, u)
+	}
+	wg.Wait()
+
+	s.AddLines("", results...)
+	return s
+}
+
+// checkReachable requests u and describes the response
+func checkReachable(u string) string {
+	ctx, cancel := context.WithTimeout(context.Background(), DoctorCheckTimeout)
+	defer cancel()
+
+	req, err := NewRequest(ctx, u)
+	if err != nil {
+		return err.Error()
+	}
+
+	start := time.Now()
+	res, err := HttpClient.Do(req)
+	if err != nil {
+		return err.Error()
+	}
+	_ = res.Body.Close()
+
+	status := res.Status + " in " + time.Since(start).Round(time.Millisecond).String()
+	if remaining := res.Header.Get("X-RateLimit-Remaining"); remaining != "" {
+		status += ", " + remaining + " GitHub API requests left"
+	}
+	return status
+}
+
+// String formats the report as text, with everything that could identify the user redacted
+func (r *DoctorReport) String() string {
+	var sb strings.Builder
+	sb.WriteString("Venticord Installer doctor report, created " + r.CreatedAt.Format(time.RFC3339) + "\n")
+	for _, s := range r.Sections {
+		sb.WriteString("\n== " + s.Title + " ==\n")
+		for _, line := range s.Lines {
+			sb.WriteString(line + "\n")
+		}
+	}
+	return Redact(sb.String())
+}
+
+// Write saves the report to file. If it ends in .zip, the config, install manifest and logs are included too
+func (r *DoctorReport) Write(file string) error {
+	if !strings.EqualFold(path.Ext(file), ".zip") {
+		if err := os.WriteFile(file, []byte(r.String()), 0644); err != nil {
+			return err
+		}
+		_ = FixOwnership(file)
+		return nil
+	}
+
+	f, err := os.Create(file)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	files := map[string]string{"report.txt": r.String()}
+	if b, err := os.ReadFile(ConfigFile); err == nil {
+		files["config.json"] = Redact(string(b))
+	}
+	if b, err := os.ReadFile(path.Join(FilesDir, InstallManifestName)); err == nil {
+		files[InstallManifestName] = Redact(string(b))
+	}
+	for _, logFile := range LogFiles() {
+		if b, err := os.ReadFile(logFile); err == nil {
+			files["logs/"+path.Base(logFile)] = Redact(string(b))
+		}
+	}
+
+	names := make([]string, 0, len(files))
+	for name := range files {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	zw := zip.NewWriter(f)
+	for _, name := range names {
+		w, err := zw.Create(name)
+		if err == nil {
+			_, err = w.Write([]byte(files[name]))
+		}
+		if err != nil {
+			return err
+		}
+	}
+	if err = zw.Close(); err != nil {
+		return err
+	}
+	_ = FixOwnership(file)
+	return nil
+}
+
+// DefaultDoctorReportFile is where the GUI saves reports
+func DefaultDoctorReportFile() string {
+	return path.Join(BaseDir, "doctor-"+time.Now().Format("20060102-150405")+".zip")
+}
+
+// Redact replaces home folders, user names, tokens and proxy credentials in s
+func Redact(s string) string {
+	if GithubToken != "" {
+		s = strings.ReplaceAll(s, GithubToken, "<github token>")
+	}
+
+	for _, proxy := range []string{ProxyOverride, InstallerConfig.Proxy, os.Getenv("HTTP_PROXY"), os.Getenv("HTTPS_PROXY")} {
+		if u, err := url.Parse(proxy); err == nil && u.User != nil {
+			s = strings.ReplaceAll(s, u.User.String(), "<credentials>")
+		}
+	}
+
+	var homes, names []string
+	for _, env := range []string{"HOME", "USERPROFILE"} {
+		homes = append(homes, os.Getenv(env))
+	}
+	names = append(names, os.Getenv("SUDO_USER"), os.Getenv("DOAS_USER"), os.Getenv("USER"), os.Getenv("USERNAME"))
+	if u, err := user.Current(); err == nil {
+		homes = append(homes, u.HomeDir)
+		// DOMAIN\user on Windows
+		names = append(names, u.Username[strings.LastIndex(u.Username, `\`)+1:])
+	}
+
+	for _, home := range homes {
+		// The home folder of root isn't personal
+		if len(home) > 1 && home != "/root" {
+			s = strings.ReplaceAll(s, home, "~")
+		}
+	}
+	for _, name := range names {
+		if len(name) > 1 && name != "root" {
+			re := regexp.MustCompile(`(^|[\s/\\:=])` + regexp.QuoteMeta(name) + `($|[\s/\\])`)
+			s = re.ReplaceAllString(s, "${1}<user>${2}")
+		}
+	}
+	return s
+}
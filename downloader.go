@@ -0,0 +1,264 @@
+/*
+ * SPDX-License-Identifier: GPL-3.0
+ * Vencord Installer, a cross platform gui/cli app for installing Vencord
+ * Copyright (c) 2023 Vendicated and Vencord contributors
+ */
+
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DownloadRetries is how often a failed download is retried before giving up
+var DownloadRetries = 4
+
+const (
+	// DownloadStallTimeout aborts a request if it receives no data for this long
+	DownloadStallTimeout = 30 * time.Second
+	// progressInterval limits how often progress is reported per file
+	progressInterval = 100 * time.Millisecond
+)
+
+var ErrShortRead = errors.New("connection closed before the whole file was received")
+
+// DownloadProgress is reported to ReportDownloadProgress while a file downloads
+type DownloadProgress struct {
+	Name string
+	Read int64
+	// Total size in bytes or -1 if the server didn't tell us
+	Total int64
+	Done  bool
+}
+
+func (p DownloadProgress) Fraction() float32 {
+	if p.Total <= 0 {
+		return 0
+	}
+	return float32(p.Read) / float32(p.Total)
+}
+
+func (p DownloadProgress) String() string {
+	if p.Total <= 0 {
+		return p.Name + " " + FormatBytes(p.Read)
+	}
+	return fmt.Sprintf("%s %d%% (%s / %s)", p.Name, int(p.Fraction()*100), FormatBytes(p.Read), FormatBytes(p.Total))
+}
+
+func FormatBytes(n int64) string {
+	switch {
+	case n >= 1<<20:
+		return fmt.Sprintf("%.1f MB", float64(n)/(1<<20))
+	case n >= 1<<10:
+		return fmt.Sprintf("%.1f KB", float64(n)/(1<<10))
+	default:
+		return strconv.FormatInt(n, 10) + " B"
+	}
+}
+
+// DownloadFile downloads url to dest. Data is written to dest.part first, which is resumed with a Range
+// request if a previous attempt was interrupted. Failed attempts are retried with exponential backoff
+func DownloadFile(name, url, dest string) error {
+	partFile := dest + ".part"
+	validatorFile := partFile + ".validator"
+
+	var err error
+	for attempt := 0; attempt <= DownloadRetries; attempt++ {
+		if attempt != 0 {
+			delay := time.Duration(1<<(attempt-1)) * 500 * time.Millisecond
+			LogInfo("Retrying download of", name, "in", delay)
+			time.Sleep(delay)
+		}
+
+		err = downloadAttempt(name, url, partFile, validatorFile)
+		if err == nil {
+			_ = os.Remove(validatorFile)
+			return os.Rename(partFile, dest)
+		}
+
+		LogWarn("Failed to download", name+":", err)
+
+		var statusErr *HttpStatusError
+		var rateErr *RateLimitError
+		var pathErr *os.PathError
+		if (errors.As(err, &statusErr) && !statusErr.IsTemporary()) || errors.As(err, &rateErr) || errors.As(err, &pathErr) {
+			break
+		}
+	}
+
+	return err
+}
+
+// IsPartialDownload reports whether the file name was left behind by an unfinished DownloadFile
+func IsPartialDownload(name string) bool {
+	return strings.HasSuffix(name, ".part") || strings.HasSuffix(name, ".part.validator")
+}
+
+// RemovePartialDownload deletes what DownloadFile left behind after failing to download to dest
+func RemovePartialDownload(dest string) {
+	_ = os.Remove(dest + ".part")
+	_ = os.Remove(dest + ".part.validator")
+}
+
+// responseValidator returns the strong ETag of res, or its Last-Modified date if it has none.
+// Resuming is only safe if the server confirms the file still matches it
+func responseValidator(res *http.Response) string {
+	if etag := res.Header.Get("ETag"); etag != "" && !strings.HasPrefix(etag, "W/") {
+		return etag
+	}
+	return res.Header.Get("Last-Modified")
+}
+
+// downloadAttempt downloads url to partFile, resuming it if validatorFile says which version of the file it contains
+func downloadAttempt(name, url, partFile, validatorFile string) error {
+	var offset int64
+	validator, _ := os.ReadFile(validatorFile)
+	if info, err := os.Stat(partFile); err == nil && len(validator) != 0 {
+		offset = info.Size()
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	stallTimer := time.AfterFunc(DownloadStallTimeout, cancel)
+	defer stallTimer.Stop()
+
+	req, err := NewRequest(ctx, url)
+	if err != nil {
+		return err
+	}
+	if offset > 0 {
+		LogInfo("Resuming download of", name, "at", FormatBytes(offset))
+		req.Header.Set("Range", "bytes="+strconv.FormatInt(offset, 10)+"-")
+		// Servers send the whole file instead if it changed since, like a nightly build being replaced
+		req.Header.Set("If-Range", string(validator))
+	}
+
+	res, err := HttpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	flags := os.O_WRONLY | os.O_CREATE
+	switch {
+	case res.StatusCode == http.StatusPartialContent && strings.HasPrefix(res.Header.Get("Content-Range"), "bytes "+strconv.FormatInt(offset, 10)+"-"):
+		flags |= os.O_APPEND
+	case res.StatusCode == http.StatusRequestedRangeNotSatisfiable && offset > 0:
+		// The partial file is bogus, start over next attempt
+		_ = os.Remove(partFile)
+		return NewHttpStatusError(url, res)
+	case res.StatusCode >= 300:
+		return NewHttpStatusError(url, res)
+	default:
+		// The server ignored our Range header or the file changed, so this is the whole file
+		offset = 0
+		flags |= os.O_TRUNC
+		if validator := responseValidator(res); validator != "" {
+			if err = os.WriteFile(validatorFile, []byte(validator), 0644); err != nil {
+				return err
+			}
+		} else {
+			_ = os.Remove(validatorFile)
+		}
+	}
+
+	out, err := os.OpenFile(partFile, flags, 0644)
+	if err != nil {
+		return err
+	}
+	defer out.Close()
+
+	progress := DownloadProgress{Name: name, Read: offset, Total: -1}
+	if res.ContentLength >= 0 {
+		progress.Total = offset + res.ContentLength
+	}
+
+	var lastReport time.Time
+	buf := make([]byte, 32*1024)
+	for {
+		n, readErr := res.Body.Read(buf)
+		if n > 0 {
+			stallTimer.Reset(DownloadStallTimeout)
+			if _, err = out.Write(buf[:n]); err != nil {
+				return err
+			}
+			progress.Read += int64(n)
+			if time.Since(lastReport) >= progressInterval {
+				lastReport = time.Now()
+				ReportDownloadProgress(progress)
+			}
+		}
+		if readErr == io.EOF {
+			break
+		}
+		if readErr != nil {
+			if ctx.Err() != nil {
+				return errors.New("no data received for " + DownloadStallTimeout.String())
+			}
+			if errors.Is(readErr, io.ErrUnexpectedEOF) {
+				return fmt.Errorf("%w (got %d of %d bytes)", ErrShortRead, progress.Read, progress.Total)
+			}
+			return readErr
+		}
+	}
+
+	if progress.Total >= 0 && progress.Read != progress.Total {
+		return fmt.Errorf("%w (got %d of %d bytes)", ErrShortRead, progress.Read, progress.Total)
+	}
+
+	progress.Done = true
+	ReportDownloadProgress(progress)
+	return nil
+}
+
+// DownloadTracker keeps the latest progress of every running download, for frontends to display
+type DownloadTracker struct {
+	mu        sync.Mutex
+	downloads map[string]DownloadProgress
+	order     []string
+}
+
+// Update stores p and returns the progress of all downloads that were running since the last Reset
+func (t *DownloadTracker) Update(p DownloadProgress) []DownloadProgress {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.downloads == nil {
+		t.downloads = make(map[string]DownloadProgress)
+	}
+	if _, ok := t.downloads[p.Name]; !ok {
+		t.order = append(t.order, p.Name)
+	}
+	t.downloads[p.Name] = p
+	return t.list()
+}
+
+func (t *DownloadTracker) List() []DownloadProgress {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.list()
+}
+
+func (t *DownloadTracker) list() []DownloadProgress {
+	list := make([]DownloadProgress, len(t.order))
+	for i, name := range t.order {
+		list[i] = t.downloads[name]
+	}
+	return list
+}
+
+func (t *DownloadTracker) Reset() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.downloads = nil
+	t.order = nil
+}
@@ -0,0 +1,357 @@
+/*
+ * SPDX-License-Identifier: GPL-3.0
+ * Vencord Installer, a cross platform gui/cli app for installing Vencord
+ * Copyright (c) 2023 Vendicated and Vencord contributors
+ */
+
+package main
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"os/exec"
+	path "path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+)
+
+type overlayKind int
+
+const (
+	overlayDeleted overlayKind = iota
+	overlayFile
+	overlayDir
+	// The entry is what the base FS has at target, as it was renamed
+	overlayAlias
+)
+
+type overlayEntry struct {
+	kind   overlayKind
+	data   []byte
+	target string
+}
+
+// DryRunFS records changes instead of making them. Reads see the base FS with the recorded changes applied,
+// so later steps behave like they would after a real run
+type DryRunFS struct {
+	base    FS
+	mu      sync.Mutex
+	overlay map[string]*overlayEntry
+	// Plan is every change that would have been made, in order
+	Plan []string
+}
+
+func NewDryRunFS(base FS) *DryRunFS {
+	return &DryRunFS{base: base, overlay: make(map[string]*overlayEntry)}
+}
+
+// dryRunPlan returns the DryRunFS if a dry run is in progress
+func dryRunPlan() *DryRunFS {
+	plan, _ := DiscordFS.(*DryRunFS)
+	return plan
+}
+
+// StateLock is held by DryRun while it swaps out DiscordFS, FilesDir, Patcher, the hashes and the Discord installs.
+// Other goroutines reading those, like the GUI, must hold it for reading. Background tasks changing state the GUI
+// renders hold it just while assigning
+var StateLock sync.RWMutex
+
+// DryRun runs fn against a DryRunFS and returns it, along with the error fn would have failed with.
+// Nothing is changed on disk, and everything fn changes in memory is restored afterwards
+func DryRun(fn func() error) (*DryRunFS, error) {
+	StateLock.Lock()
+	defer StateLock.Unlock()
+
+	plan := NewDryRunFS(DiscordFS)
+
+	prevFS, prevFilesDir, prevPatcher := DiscordFS, FilesDir, Patcher
+	prevInstalledHash, prevLatestHash := InstalledHash, LatestHash
+	installs := make([]DiscordInstall, len(discords))
+	for i, discord := range discords {
+		installs[i] = *discord.(*DiscordInstall)
+	}
+	defer func() {
+		DiscordFS, FilesDir, Patcher = prevFS, prevFilesDir, prevPatcher
+		InstalledHash, LatestHash = prevInstalledHash, prevLatestHash
+		for i, discord := range discords {
+			*discord.(*DiscordInstall) = installs[i]
+		}
+	}()
+
+	LogInfo("Starting dry run. Nothing will be changed")
+	DiscordFS = plan
+	err := fn()
+	return plan, err
+}
+
+func (d *DryRunFS) record(a ...any) {
+	msg := strings.TrimSuffix(fmt.Sprintln(a...), "\n")
+	d.Plan = append(d.Plan, msg)
+	LogInfo("[dry run]", msg)
+}
+
+// String lists the plan, one numbered step per line
+func (d *DryRunFS) String() string {
+	if len(d.Plan) == 0 {
+		return "Nothing would be changed"
+	}
+
+	var sb strings.Builder
+	for i, step := range d.Plan {
+		sb.WriteString(strconv.Itoa(i+1) + ". " + step + "\n")
+	}
+	return sb.String()
+}
+
+// resolve returns the overlay entry of name if it, or one of its parents, was changed. Otherwise it returns
+// the path name can be read from in the base FS, which differs from name if a parent was renamed
+func (d *DryRunFS) resolve(name string) (*overlayEntry, string) {
+	name = path.Clean(name)
+	for p := name; ; p = path.Dir(p) {
+		if e, ok := d.overlay[p]; ok {
+			switch {
+			case e.kind == overlayAlias:
+				rel, _ := path.Rel(p, name)
+				return nil, path.Join(e.target, rel)
+			case p == name || e.kind == overlayDeleted:
+				return e, ""
+			default:
+				// Files in created folders are in the overlay themselves
+				return &overlayEntry{kind: overlayDeleted}, ""
+			}
+		}
+		if path.Dir(p) == p {
+			return nil, name
+		}
+	}
+}
+
+func (d *DryRunFS) stat(name string) (os.FileInfo, error) {
+	e, real := d.resolve(name)
+	switch {
+	case e == nil:
+		return d.base.Stat(real)
+	case e.kind == overlayDeleted:
+		return nil, &os.PathError{Op: "stat", Path: name, Err: os.ErrNotExist}
+	default:
+		return &memFileInfo{name: path.Base(name), size: int64(len(e.data)), dir: e.kind == overlayDir}, nil
+	}
+}
+
+func (d *DryRunFS) exists(name string) bool {
+	_, err := d.stat(name)
+	return err == nil
+}
+
+// clear removes name and everything in it from the overlay
+func (d *DryRunFS) clear(name string) {
+	for p := range d.overlay {
+		if p == name || strings.HasPrefix(p, name+string(os.PathSeparator)) {
+			delete(d.overlay, p)
+		}
+	}
+}
+
+func (d *DryRunFS) Stat(name string) (os.FileInfo, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.stat(name)
+}
+
+func (d *DryRunFS) ReadDir(name string) ([]os.DirEntry, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	name = path.Clean(name)
+	info, err := d.stat(name)
+	if err != nil {
+		return nil, err
+	}
+	if !info.IsDir() {
+		return nil, &os.PathError{Op: "readdir", Path: name, Err: syscall.ENOTDIR}
+	}
+
+	children := make(map[string]os.DirEntry)
+	if e, real := d.resolve(name); e == nil {
+		entries, err := d.base.ReadDir(real)
+		if err != nil {
+			return nil, err
+		}
+		for _, entry := range entries {
+			if info, err := d.stat(path.Join(name, entry.Name())); err == nil {
+				children[entry.Name()] = fs.FileInfoToDirEntry(info)
+			}
+		}
+	}
+	for p := range d.overlay {
+		if path.Dir(p) == name {
+			if info, err := d.stat(p); err == nil {
+				children[path.Base(p)] = fs.FileInfoToDirEntry(info)
+			}
+		}
+	}
+
+	entries := make([]os.DirEntry, 0, len(children))
+	for _, entry := range children {
+		entries = append(entries, entry)
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].Name() < entries[j].Name()
+	})
+	return entries, nil
+}
+
+func (d *DryRunFS) ReadFile(name string) ([]byte, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	e, real := d.resolve(name)
+	switch {
+	case e == nil:
+		return d.base.ReadFile(real)
+	case e.kind == overlayFile:
+		return e.data, nil
+	case e.kind == overlayDir:
+		return nil, &os.PathError{Op: "read", Path: name, Err: syscall.EISDIR}
+	default:
+		return nil, &os.PathError{Op: "open", Path: name, Err: os.ErrNotExist}
+	}
+}
+
+func (d *DryRunFS) WriteFile(name string, data []byte, _ os.FileMode) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	name = path.Clean(name)
+	if !d.exists(path.Dir(name)) {
+		return &os.PathError{Op: "open", Path: name, Err: os.ErrNotExist}
+	}
+
+	d.record("Write", name, "("+FormatBytes(int64(len(data)))+")")
+	d.clear(name)
+	d.overlay[name] = &overlayEntry{kind: overlayFile, data: data}
+	return nil
+}
+
+func (d *DryRunFS) Mkdir(name string, _ os.FileMode) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	name = path.Clean(name)
+	if d.exists(name) {
+		return &os.PathError{Op: "mkdir", Path: name, Err: os.ErrExist}
+	}
+	if !d.exists(path.Dir(name)) {
+		return &os.PathError{Op: "mkdir", Path: name, Err: os.ErrNotExist}
+	}
+
+	d.record("Create folder", name)
+	d.overlay[name] = &overlayEntry{kind: overlayDir}
+	return nil
+}
+
+func (d *DryRunFS) Rename(from, to string) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	from, to = path.Clean(from), path.Clean(to)
+	if !d.exists(from) {
+		return &os.LinkError{Op: "rename", Old: from, New: to, Err: os.ErrNotExist}
+	}
+
+	d.record("Rename", from, "to", to)
+
+	moved := make(map[string]*overlayEntry)
+	if _, ok := d.overlay[from]; !ok {
+		_, real := d.resolve(from)
+		moved[to] = &overlayEntry{kind: overlayAlias, target: real}
+	}
+	for p, e := range d.overlay {
+		if p == from || strings.HasPrefix(p, from+string(os.PathSeparator)) {
+			moved[to+strings.TrimPrefix(p, from)] = e
+		}
+	}
+
+	d.clear(from)
+	d.clear(to)
+	for p, e := range moved {
+		d.overlay[p] = e
+	}
+	d.overlay[from] = &overlayEntry{kind: overlayDeleted}
+	return nil
+}
+
+func (d *DryRunFS) RemoveAll(name string) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	name = path.Clean(name)
+	if !d.exists(name) {
+		return nil
+	}
+
+	d.record("Delete", name)
+	d.clear(name)
+	d.overlay[name] = &overlayEntry{kind: overlayDeleted}
+	return nil
+}
+
+// Download records that url would be downloaded to dest
+func (d *DryRunFS) Download(name, url, dest string) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	dest = path.Clean(dest)
+	d.record("Download", name, "from", url, "to", dest)
+	d.clear(dest)
+	d.overlay[dest] = &overlayEntry{kind: overlayFile}
+}
+
+// Run records that cmd would be run
+func (d *DryRunFS) Run(cmd *exec.Cmd) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.record("Run", strings.Join(cmd.Args, " "))
+}
+
+// PlanInstall records that the Venticord build hash would be installed from source, and points
+// the patches at it as if it was
+func (d *DryRunFS) PlanInstall(hash, source string, assets []ReleaseAsset) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	versionDir := path.Join(VersionsDir, hash)
+	if len(assets) == 0 {
+		d.record("Install Venticord from", source, "to", versionDir)
+	}
+	for _, ass := range assets {
+		d.record("Download", ass.Name, "from", ass.DownloadURL, "to", versionDir)
+	}
+	d.record("Activate Venticord", hash)
+
+	FilesDir = versionDir
+	Patcher = path.Join(FilesDir, "patcher.js")
+	InstalledHash = hash
+}
+
+// DownloadInto downloads url to dest, unless this is a dry run
+func DownloadInto(name, url, dest string) error {
+	if plan := dryRunPlan(); plan != nil {
+		plan.Download(name, url, dest)
+		return nil
+	}
+	return DownloadFile(name, url, dest)
+}
+
+// RunCommand runs cmd, unless this is a dry run
+func RunCommand(cmd *exec.Cmd) error {
+	if plan := dryRunPlan(); plan != nil {
+		plan.Run(cmd)
+		return nil
+	}
+	return cmd.Run()
+}
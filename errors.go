@@ -0,0 +1,156 @@
+/*
+ * SPDX-License-Identifier: GPL-3.0
+ * Vencord Installer, a cross platform gui/cli app for installing Vencord
+ * Copyright (c) 2023 Vendicated and Vencord contributors
+ */
+
+package main
+
+import (
+	"errors"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// HttpStatusError is returned when a server answers with a non-OK status
+type HttpStatusError struct {
+	Url        string
+	StatusCode int
+	Status     string
+}
+
+func (e *HttpStatusError) Error() string {
+	return e.Url + " returned " + e.Status
+}
+
+// IsRateLimitedOrBlocked reports whether the request may succeed when made somewhere else
+func (e *HttpStatusError) IsRateLimitedOrBlocked() bool {
+	return e.StatusCode == 401 || e.StatusCode == 403 || e.StatusCode == 429
+}
+
+// IsTemporary reports whether the same request may succeed when retried later
+func (e *HttpStatusError) IsTemporary() bool {
+	return e.StatusCode == 429 || e.StatusCode >= 500
+}
+
+// NewHttpStatusError creates the error for a non-OK response. Exhausted GitHub rate limits become a *RateLimitError
+func NewHttpStatusError(url string, res *http.Response) error {
+	statusErr := &HttpStatusError{url, res.StatusCode, res.Status}
+	if res.StatusCode != 403 && res.StatusCode != 429 {
+		return statusErr
+	}
+
+	rateErr := &RateLimitError{HttpStatusError: statusErr, Remaining: -1}
+	if remaining, err := strconv.Atoi(res.Header.Get("X-RateLimit-Remaining")); err == nil {
+		rateErr.Remaining = remaining
+	}
+	if reset, err := strconv.ParseInt(res.Header.Get("X-RateLimit-Reset"), 10, 64); err == nil {
+		rateErr.Reset = time.Unix(reset, 0)
+	}
+	// Secondary rate limits only tell us how long to wait
+	if seconds, err := strconv.Atoi(res.Header.Get("Retry-After")); err == nil {
+		rateErr.Reset = time.Now().Add(time.Duration(seconds) * time.Second)
+	}
+
+	if rateErr.Remaining != 0 && rateErr.Reset.IsZero() {
+		return statusErr
+	}
+	return rateErr
+}
+
+// RateLimitError is returned when GitHub refuses a request because the rate limit is exhausted
+type RateLimitError struct {
+	*HttpStatusError
+	// Requests left in the current window, -1 if unknown
+	Remaining int
+	// When the limit resets. Zero if unknown
+	Reset time.Time
+}
+
+func (e *RateLimitError) Error() string {
+	msg := e.HttpStatusError.Error() + ": GitHub rate limit exceeded"
+	if !e.Reset.IsZero() {
+		wait := time.Until(e.Reset).Round(time.Minute)
+		if wait < time.Minute {
+			msg += ", try again in a minute"
+		} else {
+			msg += ", try again in " + FormatDuration(wait) + " (at " + e.Reset.Local().Format("15:04") + ")"
+		}
+	}
+	if GithubToken == "" {
+		msg += ". Set GITHUB_TOKEN or pass -github-token to get a higher limit"
+	}
+	return msg
+}
+
+func (e *RateLimitError) Unwrap() error {
+	return e.HttpStatusError
+}
+
+type AssetErrorKind string
+
+const (
+	AssetErrorNetwork    AssetErrorKind = "network error"
+	AssetErrorHttpStatus AssetErrorKind = "bad HTTP status"
+	AssetErrorShortRead  AssetErrorKind = "short read"
+	AssetErrorSize       AssetErrorKind = "size mismatch"
+	AssetErrorDisk       AssetErrorKind = "disk error"
+	AssetErrorChecksum   AssetErrorKind = "checksum error"
+)
+
+// AssetError is why a single release asset failed to download
+type AssetError struct {
+	Asset string
+	Kind  AssetErrorKind
+	Err   error
+}
+
+func (e *AssetError) Error() string {
+	return e.Asset + ": " + string(e.Kind) + ": " + e.Err.Error()
+}
+
+func (e *AssetError) Unwrap() error {
+	return e.Err
+}
+
+// AssetErrors lists every asset that failed during one install
+type AssetErrors []*AssetError
+
+func (e AssetErrors) Error() string {
+	lines := make([]string, len(e))
+	for i, err := range e {
+		lines[i] = "- " + err.Error()
+	}
+	return "Failed to download " + Ternary(len(e) == 1, "1 file", strconv.Itoa(len(e))+" files") + ":\n" + strings.Join(lines, "\n")
+}
+
+// Is allows errors.Is to look at every single failure. Unwrap() []error would do the same, but errors.Is only
+// understands it since Go 1.20
+func (e AssetErrors) Is(target error) bool {
+	for _, err := range e {
+		if errors.Is(err, target) {
+			return true
+		}
+	}
+	return false
+}
+
+// As is like Is, for errors.As
+func (e AssetErrors) As(target any) bool {
+	for _, err := range e {
+		if errors.As(err, target) {
+			return true
+		}
+	}
+	return false
+}
+
+// Sort sorts by asset name, so the order doesn't depend on which download finished first
+func (e AssetErrors) Sort() {
+	sort.Slice(e, func(i, j int) bool {
+		return e[i].Asset < e[j].Asset
+	})
+}
@@ -7,7 +7,6 @@
 package main
 
 import (
-	"fmt"
 	path "path/filepath"
 	"strings"
 )
@@ -20,12 +19,12 @@ var macosNames = map[string]string{
 }
 
 func ParseDiscord(p, branch string) *DiscordInstall {
-	if !ExistsFile(p) {
+	if !ExistsDiscordFile(p) {
 		return nil
 	}
 
 	resources := path.Join(p, "/Contents/Resources")
-	if !ExistsFile(resources) {
+	if !ExistsDiscordFile(resources) {
 		return nil
 	}
 
@@ -38,7 +37,7 @@ func ParseDiscord(p, branch string) *DiscordInstall {
 		path:             p,
 		branch:           branch,
 		appPath:          app,
-		isPatched:        ExistsFile(app) || IsDirectory(path.Join(resources, "app.asar")),
+		isPatched:        ExistsDiscordFile(app) || IsDiscordDirectory(path.Join(resources, "app.asar")),
 		isFlatpak:        false,
 		isSystemElectron: false,
 	}
@@ -49,7 +48,7 @@ func FindDiscords() []any {
 	for branch, dirname := range macosNames {
 		p := "/Applications/" + dirname
 		if discord := ParseDiscord(p, branch); discord != nil {
-			fmt.Println("Found Discord Install at", p)
+			LogInfo("Found Discord Install at", p)
 			discords = append(discords, discord)
 		}
 	}
@@ -8,7 +8,6 @@ package main
 
 import (
 	"errors"
-	"fmt"
 	"io/fs"
 	"os"
 	"os/user"
@@ -37,14 +36,14 @@ func init() {
 			panic("VencordInstaller must not be run as the root user. Please rerun as normal user. Use sudo or doas to run as root.")
 		}
 
-		fmt.Println("VencordInstaller was run with root privileges, actual user is", sudoUser)
-		fmt.Println("Looking up HOME of", sudoUser)
+		LogInfo("VencordInstaller was run with root privileges, actual user is", sudoUser)
+		LogDebug("Looking up HOME of", sudoUser)
 
 		u, err := user.Lookup(sudoUser)
 		if err != nil {
-			fmt.Println("Failed to lookup HOME", err)
+			LogWarn("Failed to lookup HOME", err)
 		} else {
-			fmt.Println("Actual HOME is", u.HomeDir)
+			LogDebug("Actual HOME is", u.HomeDir)
 			_ = os.Setenv("HOME", u.HomeDir)
 		}
 	} else if os.Getuid() == 0 {
@@ -81,13 +80,13 @@ func ParseDiscord(p, _ string) *DiscordInstall {
 
 	isPatched, isSystemElectron := false, false
 
-	if ExistsFile(resources) { // normal install
-		isPatched = ExistsFile(app) || IsDirectory(path.Join(resources, "app.asar"))
-	} else if ExistsFile(path.Join(p, "app.asar")) { // System electron doesn't have resources folder
+	if ExistsDiscordFile(resources) { // normal install
+		isPatched = ExistsDiscordFile(app) || IsDiscordDirectory(path.Join(resources, "app.asar"))
+	} else if ExistsDiscordFile(path.Join(p, "app.asar")) { // System electron doesn't have resources folder
 		isSystemElectron = true
-		isPatched = ExistsFile(path.Join(p, "_app.asar.unpacked"))
+		isPatched = ExistsDiscordFile(path.Join(p, "_app.asar.unpacked"))
 	} else {
-		fmt.Println("Tried to parse invalid Location:", p)
+		LogDebug("Tried to parse invalid Location:", p)
 		return nil
 	}
 
@@ -104,10 +103,10 @@ func ParseDiscord(p, _ string) *DiscordInstall {
 func FindDiscords() []any {
 	var discords []any
 	for _, dir := range DiscordDirs {
-		children, err := os.ReadDir(dir)
+		children, err := DiscordFS.ReadDir(dir)
 		if err != nil {
 			if !errors.Is(err, os.ErrNotExist) {
-				fmt.Println("Error during readdir "+dir+":", err)
+				LogWarn("Error during readdir "+dir+":", err)
 			}
 			continue
 		}
@@ -120,7 +119,7 @@ func FindDiscords() []any {
 
 			discordDir := path.Join(dir, name)
 			if discord := ParseDiscord(discordDir, ""); discord != nil {
-				fmt.Println("Found Discord install at ", discordDir)
+				LogInfo("Found Discord install at ", discordDir)
 				discords = append(discords, discord)
 			}
 		}
@@ -137,34 +136,34 @@ func FixOwnership(p string) error {
 		return nil
 	}
 
-	fmt.Println("Fixing Ownership of", p)
+	LogDebug("Fixing Ownership of", p)
 
 	sudoUser := os.Getenv("SUDO_USER")
 	if sudoUser == "" {
 		panic("SUDO_USER was empty. This point should never be reached")
 	}
 
-	fmt.Println("Looking up User", sudoUser)
+	LogDebug("Looking up User", sudoUser)
 	u, err := user.Lookup(sudoUser)
 	if err != nil {
-		fmt.Println("Lookup failed:", err)
+		LogWarn("Lookup failed:", err)
 		return err
 	}
-	fmt.Println("Lookup successful, Uid", u.Uid, "Gid", u.Gid)
+	LogDebug("Lookup successful, Uid", u.Uid, "Gid", u.Gid)
 	// This conversion is safe because of the GOOS guard above
 	uid, _ := strconv.Atoi(u.Uid)
 	gid, _ := strconv.Atoi(u.Gid)
 
 	err = path.WalkDir(p, func(path string, d fs.DirEntry, err error) error {
 		if err == nil {
-			err = os.Chown(path, uid, gid)
-			fmt.Println("chown", u.Uid+":"+u.Gid, path+":", Ternary(err == nil, "Success!", "Failed"))
+			err = os.Lchown(path, uid, gid)
+			LogDebug("chown", u.Uid+":"+u.Gid, path+":", Ternary(err == nil, "Success!", "Failed"))
 		}
 		return err
 	})
 
 	if err != nil {
-		fmt.Println("Failed to fix ownership:", err)
+		LogWarn("Failed to fix ownership:", err)
 	}
 	return err
 }
@@ -8,7 +8,6 @@ package main
 
 import (
 	"errors"
-	"fmt"
 	"os"
 	"os/exec"
 	path "path/filepath"
@@ -23,10 +22,10 @@ var windowsNames = map[string]string{
 }
 
 func ParseDiscord(p, branch string) *DiscordInstall {
-	entries, err := os.ReadDir(p)
+	entries, err := DiscordFS.ReadDir(p)
 	if err != nil {
 		if !errors.Is(err, os.ErrNotExist) {
-			fmt.Println("Error during readdir "+p+":", err)
+			LogWarn("Error during readdir "+p+":", err)
 		}
 		return nil
 	}
@@ -36,13 +35,13 @@ func ParseDiscord(p, branch string) *DiscordInstall {
 	for _, dir := range entries {
 		if dir.IsDir() && strings.HasPrefix(dir.Name(), "app-") {
 			resources := path.Join(p, dir.Name(), "resources")
-			if !ExistsFile(resources) {
+			if !ExistsDiscordFile(resources) {
 				continue
 			}
 			app := path.Join(resources, "app")
 			if app > appPath {
 				appPath = app
-				isPatched = ExistsFile(app) || IsDirectory(path.Join(resources, "app.asar"))
+				isPatched = ExistsDiscordFile(app) || IsDiscordDirectory(path.Join(resources, "app.asar"))
 			}
 		}
 	}
@@ -70,14 +69,14 @@ func FindDiscords() []any {
 
 	appData := os.Getenv("LOCALAPPDATA")
 	if appData == "" {
-		fmt.Println("%LOCALAPPDATA% is empty??????? put a thing there god damn!!!!!")
+		LogWarn("%LOCALAPPDATA% is empty??????? put a thing there god damn!!!!!")
 		return discords
 	}
 
 	for branch, dirname := range windowsNames {
 		p := path.Join(appData, dirname)
 		if discord := ParseDiscord(p, branch); discord != nil {
-			fmt.Println("Found Discord install at ", p)
+			LogInfo("Found Discord install at ", p)
 			discords = append(discords, discord)
 		}
 	}
@@ -86,9 +85,9 @@ func FindDiscords() []any {
 
 func PreparePatch(di *DiscordInstall) {
 	name := windowsNames[di.branch]
-	fmt.Println("Stabbing " + name + "...")
+	LogInfo("Stabbing " + name + "...")
 
-	_ = exec.Command("powershell", "Stop-Process -Name "+name).Run()
+	_ = RunCommand(exec.Command("powershell", "Stop-Process -Name "+name))
 }
 
 func FixOwnership(_ string) error {
@@ -101,7 +100,7 @@ func CheckScuffedInstall() bool {
 	username := os.Getenv("USERNAME")
 	programData := os.Getenv("PROGRAMDATA")
 	for _, discordName := range windowsNames {
-		if ExistsFile(path.Join(programData, username, discordName)) || ExistsFile(path.Join(programData, username, discordName)) {
+		if ExistsDiscordFile(path.Join(programData, username, discordName)) || ExistsDiscordFile(path.Join(programData, username, discordName)) {
 			HandleScuffedInstall()
 			return true
 		}
@@ -0,0 +1,408 @@
+/*
+ * SPDX-License-Identifier: GPL-3.0
+ * Vencord Installer, a cross platform gui/cli app for installing Vencord
+ * Copyright (c) 2023 Vendicated and Vencord contributors
+ */
+
+package main
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	path "path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// FS is what Discord installs are read and modified through, so a dry run or an in-memory install can stand in
+// for the real filesystem
+type FS interface {
+	Stat(name string) (os.FileInfo, error)
+	ReadDir(name string) ([]os.DirEntry, error)
+	ReadFile(name string) ([]byte, error)
+	WriteFile(name string, data []byte, perm os.FileMode) error
+	Mkdir(name string, perm os.FileMode) error
+	Rename(from, to string) error
+	RemoveAll(name string) error
+}
+
+// DiscordFS is the FS all reads and changes of Discord installs go through
+var DiscordFS FS = OsFS{}
+
+// ErrFileBusy is what FaultFS fails with for busy files, like Windows does while Discord is running
+var ErrFileBusy = errors.New("The process cannot access the file because it is being used by another process.")
+
+// ErrInjectedFault is what FaultFS fails with for FailRename
+var ErrInjectedFault = errors.New("injected fault")
+
+// OsFS is the real filesystem
+type OsFS struct{}
+
+func (OsFS) Stat(name string) (os.FileInfo, error) {
+	return os.Stat(name)
+}
+
+func (OsFS) ReadDir(name string) ([]os.DirEntry, error) {
+	return os.ReadDir(name)
+}
+
+func (OsFS) ReadFile(name string) ([]byte, error) {
+	return os.ReadFile(name)
+}
+
+func (OsFS) WriteFile(name string, data []byte, perm os.FileMode) error {
+	return os.WriteFile(name, data, perm)
+}
+
+func (OsFS) Mkdir(name string, perm os.FileMode) error {
+	return os.Mkdir(name, perm)
+}
+
+func (OsFS) Rename(from, to string) error {
+	return os.Rename(from, to)
+}
+
+func (OsFS) RemoveAll(name string) error {
+	return os.RemoveAll(name)
+}
+
+type memFile struct {
+	data    []byte
+	mode    os.FileMode
+	modTime time.Time
+}
+
+// MemFS is an FS that only exists in memory, so the patch logic can run against fake Discord installs.
+// The root folder always exists
+type MemFS struct {
+	mu    sync.Mutex
+	files map[string]*memFile
+}
+
+func NewMemFS() *MemFS {
+	return &MemFS{files: make(map[string]*memFile)}
+}
+
+func isRoot(name string) bool {
+	return path.Dir(name) == name
+}
+
+// isInside reports whether name is dir or something in it
+func isInside(name, dir string) bool {
+	return name == dir || strings.HasPrefix(name, strings.TrimSuffix(dir, string(os.PathSeparator))+string(os.PathSeparator))
+}
+
+// get returns the file at name. m.mu must be held
+func (m *MemFS) get(name string) (*memFile, bool) {
+	if isRoot(name) {
+		return &memFile{mode: os.ModeDir | 0755}, true
+	}
+	f, ok := m.files[name]
+	return f, ok
+}
+
+// isDir reports whether name is a folder. m.mu must be held
+func (m *MemFS) isDir(name string) bool {
+	f, ok := m.get(name)
+	return ok && f.mode.IsDir()
+}
+
+// MkdirAll creates the folder name along with all missing parents
+func (m *MemFS) MkdirAll(name string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.mkdirAll(path.Clean(name))
+}
+
+func (m *MemFS) mkdirAll(name string) error {
+	if f, ok := m.get(name); ok {
+		if !f.mode.IsDir() {
+			return &os.PathError{Op: "mkdir", Path: name, Err: syscall.ENOTDIR}
+		}
+		return nil
+	}
+	if err := m.mkdirAll(path.Dir(name)); err != nil {
+		return err
+	}
+	m.files[name] = &memFile{mode: os.ModeDir | 0755, modTime: time.Now()}
+	return nil
+}
+
+// AddFile creates the file name with data, along with all missing parents
+func (m *MemFS) AddFile(name string, data []byte) error {
+	if err := m.MkdirAll(path.Dir(name)); err != nil {
+		return err
+	}
+	return m.WriteFile(name, data, 0644)
+}
+
+func (m *MemFS) Stat(name string) (os.FileInfo, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	name = path.Clean(name)
+	f, ok := m.get(name)
+	if !ok {
+		return nil, &os.PathError{Op: "stat", Path: name, Err: os.ErrNotExist}
+	}
+	return &memFileInfo{name: path.Base(name), size: int64(len(f.data)), dir: f.mode.IsDir(), mode: f.mode, modTime: f.modTime}, nil
+}
+
+func (m *MemFS) ReadDir(name string) ([]os.DirEntry, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	name = path.Clean(name)
+	f, ok := m.get(name)
+	if !ok {
+		return nil, &os.PathError{Op: "open", Path: name, Err: os.ErrNotExist}
+	}
+	if !f.mode.IsDir() {
+		return nil, &os.PathError{Op: "readdirent", Path: name, Err: syscall.ENOTDIR}
+	}
+
+	var entries []os.DirEntry
+	for p, child := range m.files {
+		if path.Dir(p) == name && p != name {
+			info := &memFileInfo{name: path.Base(p), size: int64(len(child.data)), dir: child.mode.IsDir(), mode: child.mode, modTime: child.modTime}
+			entries = append(entries, fs.FileInfoToDirEntry(info))
+		}
+	}
+	// Same order as os.ReadDir
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].Name() < entries[j].Name()
+	})
+	return entries, nil
+}
+
+func (m *MemFS) ReadFile(name string) ([]byte, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	name = path.Clean(name)
+	f, ok := m.get(name)
+	if !ok {
+		return nil, &os.PathError{Op: "open", Path: name, Err: os.ErrNotExist}
+	}
+	if f.mode.IsDir() {
+		return nil, &os.PathError{Op: "read", Path: name, Err: syscall.EISDIR}
+	}
+	return append([]byte(nil), f.data...), nil
+}
+
+func (m *MemFS) WriteFile(name string, data []byte, perm os.FileMode) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	name = path.Clean(name)
+	if !m.isDir(path.Dir(name)) {
+		return &os.PathError{Op: "open", Path: name, Err: os.ErrNotExist}
+	}
+	if m.isDir(name) {
+		return &os.PathError{Op: "open", Path: name, Err: syscall.EISDIR}
+	}
+	m.files[name] = &memFile{data: append([]byte(nil), data...), mode: perm, modTime: time.Now()}
+	return nil
+}
+
+func (m *MemFS) Mkdir(name string, perm os.FileMode) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	name = path.Clean(name)
+	if _, ok := m.get(name); ok {
+		return &os.PathError{Op: "mkdir", Path: name, Err: os.ErrExist}
+	}
+	if !m.isDir(path.Dir(name)) {
+		return &os.PathError{Op: "mkdir", Path: name, Err: os.ErrNotExist}
+	}
+	m.files[name] = &memFile{mode: os.ModeDir | perm, modTime: time.Now()}
+	return nil
+}
+
+// Rename moves from to to like os.Rename does on Linux. Existing files are replaced, existing folders are not
+func (m *MemFS) Rename(from, to string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	from, to = path.Clean(from), path.Clean(to)
+	fail := func(err error) error {
+		return &os.LinkError{Op: "rename", Old: from, New: to, Err: err}
+	}
+
+	if _, ok := m.get(from); !ok || isRoot(from) {
+		return fail(os.ErrNotExist)
+	}
+	if !m.isDir(path.Dir(to)) {
+		return fail(os.ErrNotExist)
+	}
+	if from == to {
+		return nil
+	}
+	if isInside(to, from) {
+		return fail(os.ErrInvalid)
+	}
+	if m.isDir(to) {
+		return fail(os.ErrExist)
+	}
+
+	moved := make(map[string]*memFile)
+	for p, f := range m.files {
+		if isInside(p, from) {
+			delete(m.files, p)
+			moved[to+strings.TrimPrefix(p, from)] = f
+		}
+	}
+	for p, f := range moved {
+		m.files[p] = f
+	}
+	return nil
+}
+
+func (m *MemFS) RemoveAll(name string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	name = path.Clean(name)
+	for p := range m.files {
+		if isInside(p, name) {
+			delete(m.files, p)
+		}
+	}
+	return nil
+}
+
+// FaultFS passes everything on to FS, except for the operations it is told to fail.
+// Used to see how the patch logic copes with failures midway
+type FaultFS struct {
+	FS
+
+	// FailRename makes the Nth rename fail with ErrInjectedFault, counting from 1. 0 fails none
+	FailRename int
+	// Denied are paths that, along with everything in them, fail with a permission error. Only Stat still works
+	Denied []string
+	// Busy are paths that, along with everything in them, can't be renamed or deleted as if another process had them open
+	Busy []string
+
+	mu      sync.Mutex
+	renames int
+}
+
+// Renames returns how many renames were attempted so far
+func (f *FaultFS) Renames() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.renames
+}
+
+func matchesAny(name string, paths []string) bool {
+	name = path.Clean(name)
+	for _, p := range paths {
+		if isInside(name, path.Clean(p)) {
+			return true
+		}
+	}
+	return false
+}
+
+func (f *FaultFS) ReadDir(name string) ([]os.DirEntry, error) {
+	if matchesAny(name, f.Denied) {
+		return nil, &os.PathError{Op: "open", Path: name, Err: os.ErrPermission}
+	}
+	return f.FS.ReadDir(name)
+}
+
+func (f *FaultFS) ReadFile(name string) ([]byte, error) {
+	if matchesAny(name, f.Denied) {
+		return nil, &os.PathError{Op: "open", Path: name, Err: os.ErrPermission}
+	}
+	return f.FS.ReadFile(name)
+}
+
+func (f *FaultFS) WriteFile(name string, data []byte, perm os.FileMode) error {
+	if matchesAny(name, f.Denied) {
+		return &os.PathError{Op: "open", Path: name, Err: os.ErrPermission}
+	}
+	if matchesAny(name, f.Busy) {
+		return &os.PathError{Op: "open", Path: name, Err: ErrFileBusy}
+	}
+	return f.FS.WriteFile(name, data, perm)
+}
+
+func (f *FaultFS) Mkdir(name string, perm os.FileMode) error {
+	if matchesAny(name, f.Denied) {
+		return &os.PathError{Op: "mkdir", Path: name, Err: os.ErrPermission}
+	}
+	return f.FS.Mkdir(name, perm)
+}
+
+func (f *FaultFS) Rename(from, to string) error {
+	f.mu.Lock()
+	f.renames++
+	n := f.renames
+	f.mu.Unlock()
+
+	switch {
+	case n == f.FailRename:
+		return &os.LinkError{Op: "rename", Old: from, New: to, Err: ErrInjectedFault}
+	case matchesAny(from, f.Denied) || matchesAny(to, f.Denied):
+		return &os.LinkError{Op: "rename", Old: from, New: to, Err: os.ErrPermission}
+	case matchesAny(from, f.Busy) || matchesAny(to, f.Busy):
+		return &os.LinkError{Op: "rename", Old: from, New: to, Err: ErrFileBusy}
+	}
+	return f.FS.Rename(from, to)
+}
+
+func (f *FaultFS) RemoveAll(name string) error {
+	if matchesAny(name, f.Denied) {
+		return &os.PathError{Op: "unlinkat", Path: name, Err: os.ErrPermission}
+	}
+	if matchesAny(name, f.Busy) {
+		return &os.PathError{Op: "unlinkat", Path: name, Err: ErrFileBusy}
+	}
+	return f.FS.RemoveAll(name)
+}
+
+// memFileInfo describes files that only exist in memory
+type memFileInfo struct {
+	name    string
+	size    int64
+	dir     bool
+	mode    os.FileMode
+	modTime time.Time
+}
+
+func (i *memFileInfo) Name() string {
+	return i.name
+}
+
+func (i *memFileInfo) Size() int64 {
+	return i.size
+}
+
+func (i *memFileInfo) Mode() os.FileMode {
+	switch {
+	case i.mode != 0:
+		return i.mode
+	case i.dir:
+		return os.ModeDir | 0755
+	default:
+		return 0644
+	}
+}
+
+func (i *memFileInfo) ModTime() time.Time {
+	return i.modTime
+}
+
+func (i *memFileInfo) IsDir() bool {
+	return i.dir
+}
+
+func (i *memFileInfo) Sys() any {
+	return nil
+}
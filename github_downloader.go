@@ -7,10 +7,10 @@
 package main
 
 import (
-	"bufio"
+	"context"
+	"crypto/sha256"
 	"encoding/json"
 	"errors"
-	"fmt"
 	"io"
 	"net/http"
 	"os"
@@ -18,15 +18,49 @@ import (
 	"strconv"
 	"strings"
 	"sync"
+	"time"
 )
 
+type ReleaseAsset struct {
+	Name        string `json:"name"`
+	DownloadURL string `json:"browser_download_url"`
+	// Size in bytes, 0 if unknown
+	Size        int64  `json:"size"`
+	ContentType string `json:"content_type"`
+	// Like "sha256:<hex>". Only newer GitHub releases have this
+	Digest string `json:"digest"`
+}
+
 type GithubRelease struct {
-	Name    string `json:"name"`
-	TagName string `json:"tag_name"`
-	Assets  []struct {
-		Name        string `json:"name"`
-		DownloadURL string `json:"browser_download_url"`
-	} `json:"assets"`
+	Name        string    `json:"name"`
+	TagName     string    `json:"tag_name"`
+	HtmlUrl     string    `json:"html_url"`
+	PublishedAt time.Time `json:"published_at"`
+	// The release notes as markdown
+	Body   string         `json:"body"`
+	Assets []ReleaseAsset `json:"assets"`
+
+	// Url of the release JSON
+	SourceUrl string `json:"-"`
+	// When the release was last fetched or revalidated, and whether that failed so the cached copy is used
+	FetchedAt time.Time `json:"-"`
+	IsStale   bool      `json:"-"`
+}
+
+// Hash is the Venticord build hash, taken from release names like "Vencord 1a2b3c4"
+func (r *GithubRelease) Hash() string {
+	i := strings.LastIndex(r.Name, " ") + 1
+	return r.Name[i:]
+}
+
+// FindAsset returns the asset called name, or nil if the release has none
+func (r *GithubRelease) FindAsset(name string) *ReleaseAsset {
+	for i := range r.Assets {
+		if r.Assets[i].Name == name {
+			return &r.Assets[i]
+		}
+	}
+	return nil
 }
 
 var ReleaseData GithubRelease
@@ -37,166 +71,441 @@ var InstalledHash = "None"
 var LatestHash = "Unknown"
 var IsDevInstall bool
 
+// PinnedTag is the release tag to install instead of the latest one. It is stored in PinnedTagFile
+var PinnedTag string
+var PinnedTagFile string
+
 func GetGithubRelease(url, fallbackUrl string) (*GithubRelease, error) {
-	fmt.Println("Fetching", url)
+	data, err := fetchRelease(HttpClient, url)
+
+	var statusErr *HttpStatusError
+	if errors.As(err, &statusErr) {
+		triedFallback := url == fallbackUrl
+
+		// GitHub has a very strict 60 req/h rate limit and some (mostly indian) isps block github for some reason.
+		// If that is the case, try our fallback at https://vencord.dev/releases/project
+		if statusErr.IsRateLimitedOrBlocked() && !triedFallback {
+			LogWarn("Failed to fetch", url, "(status code "+strconv.Itoa(statusErr.StatusCode)+"). Trying fallback url", fallbackUrl)
+			return GetGithubRelease(fallbackUrl, fallbackUrl)
+		}
+	}
+
+	if err != nil {
+		for _, u := range []string{url, fallbackUrl} {
+			if cached, ok := GetCachedRelease(u); ok {
+				return cached, nil
+			}
+		}
+	}
+
+	return data, err
+}
 
-	req, err := http.NewRequest("GET", url, nil)
+// fetchRelease fetches and decodes the release JSON at url
+func fetchRelease(client *http.Client, url string) (*GithubRelease, error) {
+	LogInfo("Fetching", url)
+
+	req, err := NewRequest(context.Background(), url)
 	if err != nil {
-		fmt.Println("Failed to create Request", err)
+		LogWarn("Failed to create Request", err)
 		return nil, err
 	}
 
-	req.Header.Set("User-Agent", UserAgent)
+	// Conditional requests that return 304 don't count towards GitHub's rate limit
+	cached := readReleaseCache(url)
+	if cached != nil && cached.ETag != "" {
+		req.Header.Set("If-None-Match", cached.ETag)
+	}
 
-	res, err := http.DefaultClient.Do(req)
+	res, err := client.Do(req)
 	if err != nil {
-		fmt.Println("Failed to send Request", err)
+		LogWarn("Failed to send Request", err)
 		return nil, err
 	}
 
 	defer res.Body.Close()
 
-	if res.StatusCode >= 300 {
-		isRateLimitedOrBlocked := res.StatusCode == 401 || res.StatusCode == 403 || res.StatusCode == 429
-		triedFallback := url == fallbackUrl
+	if res.StatusCode == http.StatusNotModified && cached != nil {
+		LogInfo(url, "has not changed, using cached release")
+		cached.FetchedAt = time.Now()
+		writeReleaseCache(cached)
+		return cached.Release(false)
+	}
 
-		// GitHub has a very strict 60 req/h rate limit and some (mostly indian) isps block github for some reason.
-		// If that is the case, try our fallback at https://vencord.dev/releases/project
-		if isRateLimitedOrBlocked && !triedFallback {
-			fmt.Printf("Failed to fetch %s (status code %d). Trying fallback url %s\n", url, res.StatusCode, fallbackUrl)
-			return GetGithubRelease(fallbackUrl, fallbackUrl)
-		}
+	if res.StatusCode >= 300 {
+		err = NewHttpStatusError(url, res)
+		LogWarn(url, "returned Non-OK status", res.Status)
+		return nil, err
+	}
 
-		err = errors.New(res.Status)
-		fmt.Println(url, "returned Non-OK status", GithubError)
+	body, err := io.ReadAll(res.Body)
+	if err != nil {
+		LogWarn("Failed to read Response", err)
 		return nil, err
 	}
 
 	var data GithubRelease
 
-	if err = json.NewDecoder(res.Body).Decode(&data); err != nil {
-		fmt.Println("Failed to decode GitHub JSON Response", err)
+	if err = json.Unmarshal(body, &data); err != nil {
+		LogWarn("Failed to decode GitHub JSON Response", err)
 		return nil, err
 	}
 
+	data.FetchedAt = time.Now()
+	writeReleaseCache(&cachedRelease{url, res.Header.Get("ETag"), data.FetchedAt, body})
+
 	return &data, nil
 }
 
+// FetchReleaseData fetches the pinned or latest release into ReleaseData and LatestHash
+func FetchReleaseData() {
+	GithubError = nil
+	if PinnedTag != "" {
+		LogInfo("Venticord is pinned to", PinnedTag)
+	}
+
+	if err := ConfigFetchErr(); err != nil {
+		GithubError = err
+		return
+	}
+
+	data, err := FetchRelease(PinnedTag)
+	if err != nil {
+		GithubError = err
+		return
+	}
+
+	ReleaseData = *data
+	if data.IsStale {
+		LogWarn("Couldn't reach any release source. Using the release cached", FormatAge(data.FetchedAt))
+	}
+
+	LatestHash = data.Hash()
+	LogInfo("Finished fetching GitHub Data")
+	LogInfo("Latest hash is", LatestHash, "Local Install is", Ternary(LatestHash == InstalledHash, "up to date!", "outdated!"))
+}
+
+// PinRelease remembers tag as the release to install from now on, if a release source has it.
+// An empty tag or "latest" removes the pin
+func PinRelease(tag string) error {
+	tag = strings.TrimSpace(tag)
+	if tag == "" || tag == "latest" {
+		LogInfo("Unpinning Venticord version")
+		if err := os.Remove(PinnedTagFile); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return err
+		}
+		PinnedTag = ""
+		return nil
+	}
+
+	LogInfo("Pinning Venticord to", tag)
+	// Otherwise a typo would only show up the next time something is installed
+	if _, err := FetchRelease(tag); err != nil {
+		return errors.New("Can't pin " + tag + ": " + err.Error())
+	}
+	if err := os.WriteFile(PinnedTagFile, []byte(tag), 0644); err != nil {
+		return err
+	}
+	_ = FixOwnership(PinnedTagFile)
+	PinnedTag = tag
+	return nil
+}
+
 func InitGithubDownloader() {
 	GithubDoneChan = make(chan bool, 1)
 
-	IsDevInstall = os.Getenv("VENCORD_DEV_INSTALL") == "1"
-	fmt.Println("Is Dev Install: ", IsDevInstall)
-	if IsDevInstall {
+	LogDebug("Is Dev Install: ", IsDevInstall)
+	if IsDevInstall || BundlePath != "" || DevCheckout != "" {
+		// Nothing to fetch, the files come from disk
 		GithubDoneChan <- true
-		return
-	}
+	} else {
+		go func() {
+			// Make sure UI updates once the request either finished or failed
+			defer func() {
+				GithubDoneChan <- GithubError == nil
+			}()
 
-	go func() {
-		// Make sure UI updates once the request either finished or failed
-		defer func() {
-			GithubDoneChan <- GithubError == nil
+			FetchReleaseData()
 		}()
+	}
 
-		data, err := GetGithubRelease(ReleaseUrl, ReleaseUrlFallback)
-		if err != nil {
-			GithubError = err
-			return
-		}
+	DetectInstalledVersion()
+}
 
-		ReleaseData = *data
+func installLatestBuilds() error {
+	if BundlePath != "" {
+		return InstallFromBundle(BundlePath)
+	}
+	if DevCheckout != "" {
+		return InstallFromCheckout(DevCheckout)
+	}
 
-		i := strings.LastIndex(data.Name, " ") + 1
-		LatestHash = data.Name[i:]
-		fmt.Println("Finished fetching GitHub Data")
-		fmt.Println("Latest hash is", LatestHash, "Local Install is", Ternary(LatestHash == InstalledHash, "up to date!", "outdated!"))
-	}()
+	LogInfo("Installing latest builds...")
+	return installRelease(&ReleaseData)
+}
 
-	// Check hash of installed version if exists
-	f, err := os.Open(Patcher)
+// installRelease downloads the build of release into VersionsDir and activates it
+func installRelease(release *GithubRelease) (retErr error) {
+	hash := release.Hash()
+
+	rules, err := GetAssetRules(release)
+	if err != nil {
+		LogWarn(err)
+		return err
+	}
+	assets, err := SelectAssets(release, rules)
 	if err != nil {
+		LogWarn(err)
+		return err
+	}
+
+	checksums, err := GetReleaseChecksums(release)
+	if err != nil {
+		LogWarn(err)
+		return err
+	}
+	assets = skipUnverifiableAssets(assets, rules, checksums)
+
+	if plan := dryRunPlan(); plan != nil {
+		plan.PlanInstall(hash, release.SourceUrl, assets)
+		return nil
+	}
+
+	// The staging folder is kept if something fails, so partial downloads can be resumed next time
+	stagingDir, err := prepareStaging(hash)
+	if err != nil {
+		return err
+	}
+
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	var failed AssetErrors
+
+	for _, ass := range assets {
+		wg.Add(1)
+		ass := ass // Need to do this to not have the variable be overwritten halfway through
+		go func() {
+			defer wg.Done()
+			if err := downloadAsset(&ass, stagingDir, checksums); err != nil {
+				LogWarn(err)
+				mu.Lock()
+				failed = append(failed, err)
+				mu.Unlock()
+			}
+		}()
+	}
+
+	wg.Wait()
+	if len(failed) != 0 {
+		LogWarn("Not installing anything as", len(failed), "file(s) failed to download")
+		failed.Sort()
+		return failed
+	}
+
+	if retErr = commitStaging(stagingDir, hash, release.SourceUrl); retErr != nil {
 		return
 	}
-	//goland:noinspection GoUnhandledErrorResult
-	defer f.Close()
 
-	fmt.Println("Found existing Venticord Install. Checking for hash...")
-	scanner := bufio.NewScanner(f)
-	if scanner.Scan() {
-		line := scanner.Text()
-		if strings.HasPrefix(line, "// Vencord ") {
-			InstalledHash = line[11:]
-			fmt.Println("Existing hash is", InstalledHash)
-		} else {
-			fmt.Println("Didn't find hash")
+	LogInfo("Done!")
+	return
+}
+
+// skipUnverifiableAssets leaves out optional assets without a published checksum, like source maps, as they can't
+// be verified but aren't needed either. Required ones are kept, so they fail in downloadAsset
+func skipUnverifiableAssets(assets []ReleaseAsset, rules []AssetRule, checksums map[string]string) []ReleaseAsset {
+	kept := assets[:0:0]
+	for _, ass := range assets {
+		if _, ok := checksums[ass.Name]; !ok && !IsRequiredAsset(rules, ass.Name) {
+			LogWarn("Skipping optional file", ass.Name, "as no checksum was published for it")
+			continue
 		}
+		kept = append(kept, ass)
 	}
+	return kept
+}
+
+// IsDistFile reports whether name belongs to a Venticord build according to DefaultAssetRules, including source maps.
+// Used for local builds, which don't come with an AssetManifest
+func IsDistFile(name string) bool {
+	return MatchesAssetRules(DefaultAssetRules, name)
 }
 
-func installLatestBuilds() (retErr error) {
-	fmt.Println("Installing latest builds...")
+// prepareStaging creates an empty staging folder for the build hash.
+// Everything is put into a staging folder first and only swapped in once the whole set
+// was downloaded and verified. That way, a failed or interrupted update never touches the working install
+func prepareStaging(hash string) (string, error) {
+	if !IsValidVersion(hash) {
+		return "", errors.New("Refusing to install release with invalid version '" + hash + "'")
+	}
+
+	stagingDir := path.Join(VersionsDir, hash) + ".staging"
+	if err := cleanStaging(stagingDir); err != nil {
+		LogWarn("Failed to clean up old staging folder", stagingDir+":", err)
+		return "", err
+	}
+	if err := os.MkdirAll(stagingDir, 0755); err != nil {
+		LogWarn("Failed to create staging folder", stagingDir+":", err)
+		return "", err
+	}
 
 	// create an empty package.json file in our files dir.
 	// without this, node will walk up the file tree and search for a package.json in the
 	// parent folders. This might lead to issues if the user for example has ~/package.json
 	// with type: "module" in it
-	pkgJsonFile := path.Join(FilesDir, "package.json")
-	err := os.WriteFile(pkgJsonFile, []byte("{}"), 0644)
+	pkgJsonFile := path.Join(stagingDir, "package.json")
+	if err := os.WriteFile(pkgJsonFile, []byte("{}"), 0644); err != nil {
+		LogWarn("Failed to create", pkgJsonFile, err)
+	}
+
+	return stagingDir, nil
+}
+
+// cleanStaging deletes everything in stagingDir except partial downloads of a previous attempt
+func cleanStaging(stagingDir string) error {
+	entries, err := os.ReadDir(stagingDir)
 	if err != nil {
-		fmt.Println("Failed to create", pkgJsonFile, err)
+		if errors.Is(err, os.ErrNotExist) {
+			err = nil
+		}
+		return err
 	}
 
-	var wg sync.WaitGroup
+	for _, entry := range entries {
+		if !entry.IsDir() && IsPartialDownload(entry.Name()) {
+			continue
+		}
+		if err = os.RemoveAll(path.Join(stagingDir, entry.Name())); err != nil {
+			return err
+		}
+	}
+	return nil
+}
 
-	for _, ass := range ReleaseData.Assets {
-		if strings.HasPrefix(ass.Name, "patcher.js") ||
-			strings.HasPrefix(ass.Name, "preload.js") ||
-			strings.HasPrefix(ass.Name, "renderer.js") ||
-			strings.HasPrefix(ass.Name, "renderer.css") {
-			wg.Add(1)
-			ass := ass // Need to do this to not have the variable be overwritten halfway through
-			go func() {
-				defer wg.Done()
-				fmt.Println("Downloading file", ass.Name)
-
-				res, err := http.Get(ass.DownloadURL)
-				if err == nil && res.StatusCode >= 300 {
-					err = errors.New(res.Status)
-				}
-				if err != nil {
-					fmt.Println("Failed to download", ass.Name+":", err)
-					retErr = err
-					return
-				}
-				outFile := path.Join(FilesDir, ass.Name)
-				out, err := os.OpenFile(outFile, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0644)
-				if err != nil {
-					fmt.Println("Failed to create", outFile+":", err)
-					retErr = err
-					return
-				}
-				read, err := io.Copy(out, res.Body)
-				if err != nil {
-					fmt.Println("Failed to download to", outFile+":", err)
-					retErr = err
-					return
-				}
-				contentLength := res.Header.Get("Content-Length")
-				expected := strconv.FormatInt(read, 10)
-				if expected != contentLength {
-					err = errors.New("Unexpected end of input. Content-Length was " + contentLength + ", but I only read " + expected)
-					fmt.Println(err)
-					retErr = err
-					return
-				}
-			}()
+// commitStaging makes sure the staged build is complete, records it in an InstallManifest,
+// moves it into VersionsDir and activates it
+func commitStaging(stagingDir, hash, source string) error {
+	for _, file := range DistFiles {
+		if !ExistsFile(path.Join(stagingDir, file)) {
+			err := errors.New("The release is missing " + file + ". Not installing an incomplete build")
+			LogWarn(err)
+			return err
 		}
 	}
 
-	wg.Wait()
-	fmt.Println("Done!")
-	_ = FixOwnership(FilesDir)
+	if err := WriteInstallManifest(stagingDir, hash, source); err != nil {
+		LogWarn("Failed to write", InstallManifestName+":", err)
+		return err
+	}
 
-	InstalledHash = LatestHash
-	return
+	versionDir := path.Join(VersionsDir, hash)
+	if err := swapInStagedFiles(stagingDir, versionDir); err != nil {
+		return err
+	}
+	_ = FixOwnership(versionDir)
+
+	if err := ActivateVersion(hash); err != nil {
+		return err
+	}
+	PruneVersions()
+	return nil
+}
+
+// downloadAsset downloads the asset into dir and verifies its size and checksum
+func downloadAsset(ass *ReleaseAsset, dir string, checksums map[string]string) *AssetError {
+	name := ass.Name
+	LogInfo("Downloading file", name)
+
+	outFile := path.Join(dir, name)
+	if err := DownloadFile(name, ass.DownloadURL, outFile); err != nil {
+		var statusErr *HttpStatusError
+		var pathErr *os.PathError
+		switch {
+		case errors.As(err, &statusErr):
+			return &AssetError{name, AssetErrorHttpStatus, errors.New(statusErr.Status)}
+		case errors.Is(err, ErrShortRead):
+			return &AssetError{name, AssetErrorShortRead, err}
+		case errors.As(err, &pathErr):
+			return &AssetError{name, AssetErrorDisk, err}
+		default:
+			return &AssetError{name, AssetErrorNetwork, err}
+		}
+	}
+
+	if ass.Size > 0 {
+		if info, err := os.Stat(outFile); err != nil {
+			return &AssetError{name, AssetErrorDisk, err}
+		} else if info.Size() != ass.Size {
+			_ = os.Remove(outFile)
+			return &AssetError{name, AssetErrorSize, errors.New("expected " + strconv.FormatInt(ass.Size, 10) + " bytes but got " + strconv.FormatInt(info.Size(), 10))}
+		}
+	}
+
+	sum, err := hashFile(outFile)
+	if err != nil {
+		return &AssetError{name, AssetErrorDisk, err}
+	}
+	if err = VerifyChecksum(checksums, name, sum); err != nil {
+		_ = os.Remove(outFile)
+		return &AssetError{name, AssetErrorChecksum, err}
+	}
+
+	LogDebug("Verified checksum of", name)
+	return nil
+}
+
+func hashFile(file string) ([]byte, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	hash := sha256.New()
+	if _, err = io.Copy(hash, f); err != nil {
+		return nil, err
+	}
+	return hash.Sum(nil), nil
+}
+
+// swapInStagedFiles replaces targetDir with stagingDir. An existing targetDir is moved to targetDir.old
+// and put back in place if anything goes wrong
+func swapInStagedFiles(stagingDir, targetDir string) error {
+	previousDir := targetDir + ".old"
+
+	if err := os.RemoveAll(previousDir); err != nil {
+		LogWarn("Failed to delete", previousDir+":", err)
+		return err
+	}
+
+	hasPrevious := ExistsFile(targetDir)
+	if hasPrevious {
+		LogInfo("Moving", targetDir, "to", previousDir)
+		if err := os.Rename(targetDir, previousDir); err != nil {
+			err = CheckIfErrIsCauseItsBusyRn(err)
+			LogWarn("Failed to move previous install out of the way:", err)
+			return err
+		}
+	}
+
+	LogInfo("Moving", stagingDir, "to", targetDir)
+	if err := os.Rename(stagingDir, targetDir); err != nil {
+		err = CheckIfErrIsCauseItsBusyRn(err)
+		LogWarn("Failed to move new files into place:", err)
+		if hasPrevious {
+			if innerErr := os.Rename(previousDir, targetDir); innerErr != nil {
+				LogError("Failed to restore previous install from", previousDir+". Please move it back manually.", innerErr)
+			} else {
+				LogInfo("Restored previous install")
+			}
+		}
+		return err
+	}
+
+	if hasPrevious {
+		if err := os.RemoveAll(previousDir); err != nil {
+			LogWarn("Failed to delete", previousDir+". This is whatever but you might want to delete it manually.", err)
+		}
+	}
+
+	return nil
 }
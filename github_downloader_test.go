@@ -0,0 +1,143 @@
+/*
+ * SPDX-License-Identifier: GPL-3.0
+ * Vencord Installer, a cross platform gui/cli app for installing Vencord
+ * Copyright (c) 2023 Vendicated and Vencord contributors
+ */
+
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	path "path/filepath"
+	"testing"
+)
+
+// useRelease makes ReleaseData a release of files with their checksums, served by handler and installed into
+// a temporary VersionsDir
+func useRelease(t *testing.T, files map[string][]byte, handler http.HandlerFunc) {
+	t.Helper()
+
+	srv := httptest.NewServer(handler)
+	t.Cleanup(srv.Close)
+
+	release := GithubRelease{Name: "Venticord abc1234", TagName: "abc1234", SourceUrl: srv.URL + "/release"}
+	for name, data := range files {
+		sum := sha256.Sum256(data)
+		release.Assets = append(release.Assets, ReleaseAsset{
+			Name:        name,
+			DownloadURL: srv.URL + "/" + name,
+			Digest:      "sha256:" + hex.EncodeToString(sum[:]),
+		})
+	}
+
+	prevRelease, prevVersionsDir, prevRetries := ReleaseData, VersionsDir, DownloadRetries
+	prevBundlePath, prevDevCheckout := BundlePath, DevCheckout
+	prevActiveVersionFile, prevFilesDir, prevPatcher := ActiveVersionFile, FilesDir, Patcher
+	prevInstalledHash, prevInstalledManifest := InstalledHash, InstalledManifest
+	t.Cleanup(func() {
+		ReleaseData, VersionsDir, DownloadRetries = prevRelease, prevVersionsDir, prevRetries
+		BundlePath, DevCheckout = prevBundlePath, prevDevCheckout
+		ActiveVersionFile, FilesDir, Patcher = prevActiveVersionFile, prevFilesDir, prevPatcher
+		InstalledHash, InstalledManifest = prevInstalledHash, prevInstalledManifest
+	})
+
+	ReleaseData = release
+	VersionsDir = t.TempDir()
+	ActiveVersionFile = path.Join(VersionsDir, "active-version")
+	BundlePath, DevCheckout = "", ""
+	// Both failures would fail again, no need to wait for the backoff
+	DownloadRetries = 0
+}
+
+func TestInstallLatestBuildsReportsEveryFailedAsset(t *testing.T) {
+	files := map[string][]byte{
+		"patcher.js":   []byte("patcher"),
+		"preload.js":   []byte("preload"),
+		"renderer.js":  []byte("renderer"),
+		"renderer.css": []byte("renderer css"),
+	}
+	useRelease(t, files, func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/renderer.js":
+			http.NotFound(w, r)
+		case "/renderer.css":
+			// Promise the whole file, then hang up halfway through
+			w.Header().Set("Content-Length", "12")
+			_, _ = w.Write([]byte("render"))
+		default:
+			_, _ = w.Write(files[path.Base(r.URL.Path)])
+		}
+	})
+
+	err := installLatestBuilds()
+
+	var failed AssetErrors
+	if !errors.As(err, &failed) {
+		t.Fatal("Expected AssetErrors, got", err)
+	}
+	if len(failed) != 2 {
+		t.Fatal("Expected 2 failed assets, got", failed)
+	}
+	// Sorted by name
+	if failed[0].Asset != "renderer.css" || failed[0].Kind != AssetErrorShortRead {
+		t.Error("Expected short read of renderer.css, got", failed[0])
+	}
+	if failed[1].Asset != "renderer.js" || failed[1].Kind != AssetErrorHttpStatus {
+		t.Error("Expected bad HTTP status of renderer.js, got", failed[1])
+	}
+	if !errors.Is(err, ErrShortRead) {
+		t.Error("ErrShortRead not found in", err)
+	}
+
+	// Nothing may be installed if one asset failed
+	if _, err = os.Stat(path.Join(VersionsDir, "abc1234")); !errors.Is(err, os.ErrNotExist) {
+		t.Error("Release was installed despite failed assets")
+	}
+}
+
+func TestInstallLatestBuildsSkipsOptionalAssetsWithoutChecksum(t *testing.T) {
+	files := map[string][]byte{
+		"patcher.js":      []byte("patcher"),
+		"preload.js":      []byte("preload"),
+		"renderer.js":     []byte("renderer"),
+		"renderer.css":    []byte("renderer css"),
+		"renderer.js.map": []byte("source map"),
+	}
+	useRelease(t, files, func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write(files[path.Base(r.URL.Path)])
+	})
+	ReleaseData.FindAsset("renderer.js.map").Digest = ""
+
+	if err := installLatestBuilds(); err != nil {
+		t.Fatal(err)
+	}
+	if !ExistsFile(path.Join(VersionsDir, "abc1234", "renderer.js")) {
+		t.Fatal("Release was not installed")
+	}
+	if ExistsFile(path.Join(VersionsDir, "abc1234", "renderer.js.map")) {
+		t.Error("Optional asset without checksum was installed")
+	}
+}
+
+func TestInstallLatestBuildsRefusesRequiredAssetsWithoutChecksum(t *testing.T) {
+	files := map[string][]byte{
+		"patcher.js":   []byte("patcher"),
+		"preload.js":   []byte("preload"),
+		"renderer.js":  []byte("renderer"),
+		"renderer.css": []byte("renderer css"),
+	}
+	useRelease(t, files, func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write(files[path.Base(r.URL.Path)])
+	})
+	ReleaseData.FindAsset("renderer.js").Digest = ""
+
+	var failed AssetErrors
+	if err := installLatestBuilds(); !errors.As(err, &failed) || len(failed) != 1 || failed[0].Kind != AssetErrorChecksum {
+		t.Fatal("Expected checksum error for renderer.js, got", err)
+	}
+}
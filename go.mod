@@ -6,6 +6,7 @@ require (
 	github.com/AllenDang/giu v0.6.2
 	github.com/AllenDang/imgui-go v1.12.1-0.20220322114136-499bbf6a42ad
 	github.com/ProtonMail/go-appdir v1.1.0
+	golang.org/x/net v0.2.0
 )
 
 require (
@@ -19,5 +20,6 @@ require (
 	github.com/stretchr/testify v1.7.2 // indirect
 	golang.org/x/image v0.1.0 // indirect
 	golang.org/x/sys v0.2.0 // indirect
+	golang.org/x/text v0.4.0 // indirect
 	gopkg.in/eapache/queue.v1 v1.1.0 // indirect
 )
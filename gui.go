@@ -12,7 +12,6 @@ import (
 	"bytes"
 	_ "embed"
 	"errors"
-	"fmt"
 	g "github.com/AllenDang/giu"
 	"github.com/AllenDang/imgui-go"
 	"image"
@@ -24,12 +23,14 @@ import (
 	"runtime"
 	"strconv"
 	"strings"
+	"sync"
 )
 
 var (
-	discords        []any
-	radioIdx        int
-	customChoiceIdx int
+	discords []any
+	// Which discords are selected. The last entry is the custom location
+	selectedInstalls []bool
+	customChoiceIdx  int
 
 	customDir              string
 	autoCompleteDir        string
@@ -44,6 +45,25 @@ var (
 	modalMessage = "You should never see this"
 
 	acceptedOpenAsar bool
+	dryRunMode       bool
+
+	installedVersions []string
+	versionIdx        int32
+
+	pinTagInput string
+
+	changelog        string
+	changelogVersion string
+
+	logLevelIdx   = int32(LevelInfo)
+	logLevelNames = []string{"Debug", "Info", "Warnings", "Errors"}
+
+	pendingJournals []*Journal
+
+	isBusy          bool
+	downloadTracker DownloadTracker
+	pendingPopups   []string
+	popupLock       sync.Mutex
 
 	win *g.MasterWindow
 )
@@ -56,6 +76,13 @@ func main() {
 	discords = FindDiscords()
 
 	customChoiceIdx = len(discords)
+	selectedInstalls = make([]bool, len(discords)+1)
+	selectedInstalls[0] = true
+	installedVersions = InstalledVersions()
+
+	if pendingJournals = PendingJournals(); len(pendingJournals) != 0 {
+		openPopup("#journal")
+	}
 
 	go func() {
 		<-GithubDoneChan
@@ -71,8 +98,8 @@ func main() {
 
 	icon, _, err := image.Decode(bytes.NewReader(iconBytes))
 	if err != nil {
-		fmt.Println("Failed to load application icon", err)
-		fmt.Println(iconBytes, len(iconBytes))
+		LogError("Failed to load application icon", err)
+		LogDebug(iconBytes, len(iconBytes))
 	} else {
 		win.SetIcon([]image.Image{icon})
 	}
@@ -93,17 +120,54 @@ func (w *CondWidget) Build() {
 	}
 }
 
-func getChosenInstall() *DiscordInstall {
-	var choice *DiscordInstall
-	if radioIdx == customChoiceIdx {
-		choice = ParseDiscord(customDir, "")
+// getSelectedDiscords returns the selected installs, not including the custom location
+func getSelectedDiscords() []*DiscordInstall {
+	var installs []*DiscordInstall
+	for i, discord := range discords {
+		if selectedInstalls[i] {
+			installs = append(installs, discord.(*DiscordInstall))
+		}
+	}
+	return installs
+}
+
+// moveSelection selects only the install delta entries away from the first selected one, including the custom
+// location. That's what the arrow keys did when just one install could be selected
+func moveSelection(delta int) {
+	current := 0
+	for i, selected := range selectedInstalls {
+		if selected {
+			current = i
+			break
+		}
+	}
+
+	next := current + delta
+	if next < 0 || next > customChoiceIdx {
+		return
+	}
+	for i := range selectedInstalls {
+		selectedInstalls[i] = i == next
+	}
+}
+
+// getChosenInstalls returns all selected installs including the custom location. Nil if there are none or
+// the custom location is invalid
+func getChosenInstalls() []*DiscordInstall {
+	installs := getSelectedDiscords()
+	if selectedInstalls[customChoiceIdx] {
+		choice := ParseDiscord(customDir, "")
 		if choice == nil {
-			g.OpenPopup("#invalid-custom-location")
+			openPopup("#invalid-custom-location")
+			return nil
 		}
-	} else {
-		choice = discords[radioIdx].(*DiscordInstall)
+		installs = append(installs, choice)
 	}
-	return choice
+
+	if len(installs) == 0 {
+		ShowModal("No install selected", "Select at least one Discord install first.")
+	}
+	return installs
 }
 
 func InstallLatestBuilds() (err error) {
@@ -111,29 +175,306 @@ func InstallLatestBuilds() (err error) {
 		return
 	}
 
+	downloadTracker.Reset()
 	err = installLatestBuilds()
 	if err != nil {
 		ShowModal("WHERE THE HELL IS VENTICORD???", "Failed to install the latest Venticord builds from GitHub:\n"+err.Error())
 	}
+	// During a dry run, DryRun holds the lock and nothing was installed
+	if dryRunPlan() == nil {
+		refreshInstalledVersions()
+	}
 	return
 }
 
+// refreshInstalledVersions lists the installed versions again after some were installed or deleted
+func refreshInstalledVersions() {
+	versions := InstalledVersions()
+
+	StateLock.Lock()
+	defer StateLock.Unlock()
+	installedVersions = versions
+	versionIdx = 0
+}
+
+func handlePin(tag string) {
+	// Pinning checks that the release exists first
+	runInBackground(func() {
+		if err := PinRelease(tag); err != nil {
+			ShowModal("Failed to pin version", err.Error())
+			return
+		}
+		StateLock.Lock()
+		pinTagInput = ""
+		LatestHash = "Unknown"
+		StateLock.Unlock()
+
+		FetchReleaseData()
+	})
+}
+
+func handleSwitchVersion() {
+	if int(versionIdx) >= len(installedVersions) {
+		return
+	}
+
+	hash := installedVersions[versionIdx]
+	// Repointing installs can take a moment, for example to grant Flatpaks access
+	runInBackground(func() {
+		if err := RollbackVersion(hash); err != nil {
+			ShowModal("Failed to switch to "+hash, err.Error())
+		} else {
+			ShowModal("Switched Venticord Version", "All patched installs now use "+hash+".\nRestart Discord to apply it.")
+		}
+	})
+}
+
+func handleChangelog() {
+	// The changelog depends on both versions, so only reuse it if neither changed
+	version := InstalledHash + "..." + LatestHash
+	if changelogVersion != version {
+		changelogVersion = version
+		changelog = "Loading changelog..."
+		go func() {
+			text := GetChangelog()
+
+			StateLock.Lock()
+			changelog = text
+			StateLock.Unlock()
+			g.Update()
+		}()
+	}
+	openPopup("#changelog")
+}
+
+func handleCheckIntegrity() {
+	runInBackground(func() {
+		report := CheckIntegrity()
+		if report.IsOk() {
+			ShowModal("All good!", "Your Venticord files are intact and all patched installs load them.")
+			return
+		}
+
+		downloadTracker.Reset()
+		if _, err := RepairIntegrity(report); err != nil {
+			ShowModal("Failed to repair", err.Error())
+		} else {
+			ShowModal("Repaired!", report.String()+"\n\nAll of this has been fixed. Restart Discord to apply it.")
+		}
+		refreshInstalledVersions()
+	})
+}
+
+func handleDoctor() {
+	runInBackground(func() {
+		file := DefaultDoctorReportFile()
+		if err := RunDoctor().Write(file); err != nil {
+			ShowModal("Failed to create support report", err.Error())
+			return
+		}
+		ShowModal("Support report saved", "Saved to "+file+"\n\nAttach it when asking for help. Your user name and home folder have been removed from it.")
+		g.OpenURL("file://" + BaseDir)
+	})
+}
+
+// handleRecover finishes or undoes the first interrupted patch
+func handleRecover(forward bool) {
+	runInBackground(func() {
+		StateLock.Lock()
+		j := pendingJournals[0]
+		pendingJournals = pendingJournals[1:]
+		remaining := len(pendingJournals)
+		StateLock.Unlock()
+
+		if err := Ternary(forward, j.RollForward, j.RollBack)(); err != nil {
+			ShowModal("Failed to recover", err.Error()+"\n\nYou might have to reinstall Discord.")
+		} else if remaining != 0 {
+			openPopup("#journal")
+		}
+
+		// Whether they are patched changed. The selection only stays valid if the installs did too
+		found := FindDiscords()
+		StateLock.Lock()
+		if len(found) == len(discords) {
+			discords = found
+		}
+		StateLock.Unlock()
+	})
+}
+
+// go can you give me []any? part 3
+func visibleLogs() []any {
+	var visible []any
+	for _, entry := range RecentLogs() {
+		if entry.Level >= LogLevel(logLevelIdx) {
+			visible = append(visible, entry)
+		}
+	}
+	return visible
+}
+
+func logColor(level LogLevel) color.Color {
+	switch level {
+	case LevelDebug:
+		return color.RGBA{R: 0x99, G: 0x99, B: 0x99, A: 0xFF}
+	case LevelWarn:
+		return DiscordYellow
+	case LevelError:
+		return DiscordRed
+	default:
+		return color.White
+	}
+}
+
+func ReportDownloadProgress(p DownloadProgress) {
+	downloadTracker.Update(p)
+	g.Update()
+}
+
+// runInBackground runs fn without blocking the UI, so progress can be shown while it downloads
+func runInBackground(fn func()) {
+	if isBusy {
+		return
+	}
+	isBusy = true
+
+	go func() {
+		defer func() {
+			StateLock.Lock()
+			isBusy = false
+			StateLock.Unlock()
+			g.Update()
+		}()
+		fn()
+	}()
+}
+
+// openPopup opens the popup id on the next frame. Popups can only be opened from the UI thread
+func openPopup(id string) {
+	popupLock.Lock()
+	pendingPopups = append(pendingPopups, id)
+	popupLock.Unlock()
+	g.Update()
+}
+
+func openPendingPopups() {
+	popupLock.Lock()
+	defer popupLock.Unlock()
+	for _, id := range pendingPopups {
+		g.OpenPopup(id)
+	}
+	pendingPopups = nil
+}
+
 func handlePatch() {
-	choice := getChosenInstall()
-	if choice != nil {
-		choice.Patch()
+	runInBackground(func() {
+		if dryRunMode {
+			showDryRun("patch", func(installs []*DiscordInstall) error {
+				_, err := ForEachInstall(installs, "patch", (*DiscordInstall).patch)
+				return err
+			})
+			return
+		}
+		patchInstalls(getChosenInstalls())
+	})
+}
+
+func handleRepatch() {
+	runInBackground(func() {
+		if dryRunMode {
+			showDryRun("repatch", func(installs []*DiscordInstall) error {
+				// Not InstallLatestBuilds, that shows its own dialog
+				if !IsDevInstall {
+					if err := installLatestBuilds(); err != nil {
+						return err
+					}
+				}
+				_, err := ForEachInstall(installs, "repatch", (*DiscordInstall).patch)
+				return err
+			})
+			return
+		}
+		if IsDevInstall || InstallLatestBuilds() == nil {
+			patchInstalls(getChosenInstalls())
+		}
+	})
+}
+
+func patchInstalls(installs []*DiscordInstall) {
+	if len(installs) == 1 {
+		installs[0].Patch()
+		return
+	}
+	if len(installs) == 0 || CheckScuffedInstall() {
+		return
+	}
+
+	// Otherwise the first patch would do this and only show the error as dialog
+	if LatestHash != InstalledHash && InstallLatestBuilds() != nil {
+		return
 	}
+	showResults("patch", "Close Discord if it's open, then start it again to launch into Venticord!", (*DiscordInstall).patch, installs)
 }
 
 func handleUnpatch() {
-	choice := getChosenInstall()
-	if choice != nil {
-		choice.Unpatch()
+	runInBackground(func() {
+		if dryRunMode {
+			showDryRun("unpatch", func(installs []*DiscordInstall) error {
+				_, err := ForEachInstall(installs, "unpatch", (*DiscordInstall).unpatch)
+				return err
+			})
+			return
+		}
+		installs := getChosenInstalls()
+		if len(installs) == 1 {
+			installs[0].Unpatch()
+		} else if len(installs) != 0 {
+			showResults("unpatch", "It's very sad to see you go.", (*DiscordInstall).unpatch, installs)
+		}
+	})
+}
+
+// showResults runs fn on all installs, even if some fail, and shows what happened to each in one dialog
+func showResults(action, successMessage string, fn func(di *DiscordInstall) error, installs []*DiscordInstall) {
+	results, err := ForEachInstall(installs, action, fn)
+	if err != nil {
+		ShowModal("Failed to "+action+" some installs", SummarizeResults(results))
+	} else {
+		ShowModal("Done!", SummarizeResults(results)+"\n\n"+successMessage)
 	}
 }
 
+// showDryRun runs fn on the chosen installs against a virtual filesystem and shows what it would change
+func showDryRun(action string, fn func(installs []*DiscordInstall) error) {
+	installs := getChosenInstalls()
+	if len(installs) == 0 {
+		return
+	}
+
+	plan, err := DryRun(func() error {
+		return fn(installs)
+	})
+	msg := "Nothing was changed. This is what would be done:\n\n" + plan.String()
+	if err != nil {
+		msg += "\nAfter that, it would fail:\n" + err.Error()
+	}
+	ShowModal("Dry run: "+action, msg)
+}
+
+// isOpenAsarSelected reports whether all selected installs use OpenAsar
+func isOpenAsarSelected() bool {
+	installs := getSelectedDiscords()
+	for _, di := range installs {
+		if !di.IsOpenAsar() {
+			return false
+		}
+	}
+	return len(installs) != 0 && !selectedInstalls[customChoiceIdx]
+}
+
 func handleOpenAsar() {
-	if acceptedOpenAsar || getChosenInstall().IsOpenAsar() {
+	// Dry runs don't install anything, so there is nothing to accept yet
+	if acceptedOpenAsar || dryRunMode || isOpenAsarSelected() {
 		handleOpenAsarConfirmed()
 		return
 	}
@@ -142,24 +483,64 @@ func handleOpenAsar() {
 }
 
 func handleOpenAsarConfirmed() {
-	choice := getChosenInstall()
-	if choice != nil {
+	runInBackground(func() {
+		if dryRunMode {
+			showDryRun("OpenAsar", func(installs []*DiscordInstall) error {
+				// Same as below, multiple installs are only uninstalled if all of them use OpenAsar
+				uninstall := isOpenAsarSelected()
+				if len(installs) == 1 {
+					uninstall = installs[0].IsOpenAsar()
+				}
+				_, err := ForEachInstall(installs, Ternary(uninstall, "uninstall OpenAsar from", "install OpenAsar on"), func(di *DiscordInstall) error {
+					if uninstall {
+						return di.UninstallOpenAsar()
+					}
+					if di.IsOpenAsar() {
+						return nil
+					}
+					return di.InstallOpenAsar()
+				})
+				return err
+			})
+			return
+		}
+
+		installs := getChosenInstalls()
+		if len(installs) > 1 {
+			downloadTracker.Reset()
+			if isOpenAsarSelected() {
+				showResults("uninstall OpenAsar from", "If Discord is still open, fully close it first. Then start it again and it should be back to stock!", (*DiscordInstall).UninstallOpenAsar, installs)
+			} else {
+				showResults("install OpenAsar on", "If Discord is still open, fully close it first. Then start it again and verify OpenAsar installed successfully!", func(di *DiscordInstall) error {
+					if di.IsOpenAsar() {
+						return nil
+					}
+					return di.InstallOpenAsar()
+				}, installs)
+			}
+			return
+		}
+
+		if len(installs) == 0 {
+			return
+		}
+
+		choice := installs[0]
 		if choice.IsOpenAsar() {
 			if err := choice.UninstallOpenAsar(); err != nil {
 				handleErr(choice, err, "uninstall OpenAsar from")
 			} else {
-				g.OpenPopup("#openasar-unpatched")
-				g.Update()
+				openPopup("#openasar-unpatched")
 			}
 		} else {
+			downloadTracker.Reset()
 			if err := choice.InstallOpenAsar(); err != nil {
 				handleErr(choice, err, "install OpenAsar on")
 			} else {
-				g.OpenPopup("#openasar-patched")
-				g.Update()
+				openPopup("#openasar-patched")
 			}
 		}
-	}
+	})
 }
 
 func handleErr(di *DiscordInstall, err error, action string) {
@@ -180,7 +561,7 @@ func handleErr(di *DiscordInstall, err error, action string) {
 }
 
 func HandleScuffedInstall() {
-	g.OpenPopup("#scuffed-install")
+	openPopup("#scuffed-install")
 }
 
 func (di *DiscordInstall) Patch() {
@@ -190,7 +571,7 @@ func (di *DiscordInstall) Patch() {
 	if err := di.patch(); err != nil {
 		handleErr(di, err, "patch")
 	} else {
-		g.OpenPopup("#patched")
+		openPopup("#patched")
 	}
 }
 
@@ -198,7 +579,7 @@ func (di *DiscordInstall) Unpatch() {
 	if err := di.unpatch(); err != nil {
 		handleErr(di, err, "unpatch")
 	} else {
-		g.OpenPopup("#unpatched")
+		openPopup("#unpatched")
 	}
 }
 
@@ -206,7 +587,7 @@ func onCustomInputChanged() {
 	p := customDir
 	if len(p) != 0 {
 		// Select the custom option for people
-		radioIdx = customChoiceIdx
+		selectedInstalls[customChoiceIdx] = true
 	}
 
 	dir := path.Dir(p)
@@ -259,12 +640,6 @@ func makeAutoComplete() []any {
 	return candidates
 }
 
-func makeRadioOnChange(i int) func() {
-	return func() {
-		radioIdx = i
-	}
-}
-
 func renderFilesDirErr() g.Widget {
 	return g.Layout{
 		g.Dummy(0, 50),
@@ -350,11 +725,125 @@ func RawInfoModal(id, title, description string, isOpenAsar bool) g.Widget {
 		)
 }
 
+func ChangelogModal(w float32) g.Widget {
+	return g.Style().
+		SetStyle(g.StyleVarWindowPadding, 30, 30).
+		SetStyleFloat(g.StyleVarWindowRounding, 12).
+		To(
+			g.PopupModal("#changelog").
+				Flags(g.WindowFlagsNoTitleBar).
+				Layout(
+					g.Style().SetFontSize(20).To(
+						g.Child().
+							Size(w-60, 500).
+							Layout(
+								g.Markdown(&changelog),
+							),
+					),
+					g.Dummy(0, 10),
+					g.Align(g.AlignCenter).To(
+						g.Button("Close").
+							OnClick(func() {
+								g.CloseCurrentPopup()
+							}).
+							Size(100, 30),
+					),
+				),
+		)
+}
+
+func LogViewerModal(w float32) g.Widget {
+	return g.Style().
+		SetStyle(g.StyleVarWindowPadding, 30, 30).
+		SetStyleFloat(g.StyleVarWindowRounding, 12).
+		To(
+			g.PopupModal("#logs").
+				Flags(g.WindowFlagsNoTitleBar).
+				Layout(
+					g.Row(
+						g.Label("Show"),
+						g.Combo("##loglevel", logLevelNames[logLevelIdx], logLevelNames, &logLevelIdx).Size(150),
+					),
+					g.Dummy(0, 10),
+					g.Child().
+						Size(w-60, 500).
+						Layout(
+							g.RangeBuilder("logs", visibleLogs(), func(_ int, v any) g.Widget {
+								entry := v.(LogEntry)
+								return g.Style().
+									SetColor(g.StyleColorText, logColor(entry.Level)).
+									To(
+										g.Label(entry.String()).Wrapped(true),
+									)
+							}),
+						),
+					g.Dummy(0, 10),
+					g.Align(g.AlignCenter).To(
+						g.Row(
+							g.Button("Open log folder").
+								OnClick(func() {
+									g.OpenURL("file://" + LogDir)
+								}).
+								Size(150, 30),
+							g.Button("Close").
+								OnClick(func() {
+									g.CloseCurrentPopup()
+								}).
+								Size(100, 30),
+						),
+					),
+				),
+		)
+}
+
+func JournalModal() g.Widget {
+	operation, description := "patch", ""
+	if len(pendingJournals) != 0 {
+		operation, description = pendingJournals[0].Operation, pendingJournals[0].String()
+	}
+
+	return g.Style().
+		SetStyle(g.StyleVarWindowPadding, 30, 30).
+		SetStyleFloat(g.StyleVarWindowRounding, 12).
+		To(
+			g.PopupModal("#journal").
+				Flags(g.WindowFlagsNoTitleBar | g.WindowFlagsAlwaysAutoResize).
+				Layout(
+					g.Align(g.AlignCenter).To(
+						g.Style().SetFontSize(30).To(
+							g.Label("Interrupted "+operation),
+						),
+						g.Style().SetFontSize(20).To(
+							g.Label(description+".\nDiscord might not start until you finish or undo it."),
+						),
+						g.Dummy(0, 20),
+						// Otherwise the click would be ignored with the popup already closed
+						g.Style().SetDisabled(isBusy).To(
+							g.Row(
+								g.Button("Finish").
+									OnClick(func() {
+										g.CloseCurrentPopup()
+										handleRecover(true)
+									}).
+									Size(100, 30),
+								g.Button("Undo").
+									OnClick(func() {
+										g.CloseCurrentPopup()
+										handleRecover(false)
+									}).
+									Size(100, 30),
+							),
+						),
+					),
+				),
+		)
+}
+
 func ShowModal(title, desc string) {
 	modalTitle = title
 	modalMessage = desc
 	modalId++
-	g.OpenPopup("#modal" + strconv.Itoa(modalId))
+	openPopup("#modal" + strconv.Itoa(modalId))
 }
 
 func renderInstaller() g.Widget {
@@ -362,11 +851,8 @@ func renderInstaller() g.Widget {
 	wi, _ := win.GetSize()
 	w := float32(wi) - 96
 
-	var currentDiscord *DiscordInstall
-	if radioIdx != customChoiceIdx {
-		currentDiscord = discords[radioIdx].(*DiscordInstall)
-	}
-	var isOpenAsar = currentDiscord != nil && currentDiscord.IsOpenAsar()
+	var hasSelection = len(getSelectedDiscords()) != 0
+	var isOpenAsar = isOpenAsarSelected()
 
 	layout := g.Layout{
 		g.Dummy(0, 20),
@@ -385,7 +871,7 @@ func renderInstaller() g.Widget {
 		g.Dummy(0, 5),
 
 		g.Style().SetFontSize(30).To(
-			g.Label("Select the install you want to launch into the Venticord world"),
+			g.Label("Select the installs you want to launch into the Venticord world"),
 		),
 
 		&CondWidget{len(discords) == 0, func() g.Widget {
@@ -400,12 +886,10 @@ func renderInstaller() g.Widget {
 				if d.isPatched {
 					text += " | Already Launched"
 				}
-				return g.RadioButton(text, radioIdx == i).
-					OnChange(makeRadioOnChange(i))
+				return g.Checkbox(text, &selectedInstalls[i])
 			}),
 
-			g.RadioButton("Custom Install Location", radioIdx == customChoiceIdx).
-				OnChange(makeRadioOnChange(customChoiceIdx)),
+			g.Checkbox("Custom Install Location", &selectedInstalls[customChoiceIdx]),
 		),
 
 		g.Dummy(0, 5),
@@ -461,7 +945,7 @@ func renderInstaller() g.Widget {
 			g.Row(
 				g.Style().
 					SetColor(g.StyleColorButton, DiscordGreen).
-					SetDisabled(GithubError != nil).
+					SetDisabled(GithubError != nil || isBusy).
 					To(
 						g.Button("Patch with Venticord!").
 							OnClick(handlePatch).
@@ -470,24 +954,16 @@ func renderInstaller() g.Widget {
 					),
 				g.Style().
 					SetColor(g.StyleColorButton, DiscordBlue).
-					SetDisabled(GithubError != nil).
+					SetDisabled(GithubError != nil || isBusy).
 					To(
 						g.Button("Repatch (Repair)").
-							OnClick(func() {
-								if IsDevInstall {
-									handlePatch()
-								} else {
-									err := InstallLatestBuilds()
-									if err == nil {
-										handlePatch()
-									}
-								}
-							}).
+							OnClick(handleRepatch).
 							Size((w-40)/4, 50),
 						Tooltip("Repatch (Update)"),
 					),
 				g.Style().
 					SetColor(g.StyleColorButton, DiscordRed).
+					SetDisabled(isBusy).
 					To(
 						g.Button("Vanilla-fy (Uninstall)").
 							OnClick(handleUnpatch).
@@ -496,15 +972,29 @@ func renderInstaller() g.Widget {
 					),
 				g.Style().
 					SetColor(g.StyleColorButton, Ternary(isOpenAsar, DiscordRed, DiscordGreen)).
+					SetDisabled(isBusy).
 					To(
-						g.Button(Ternary(isOpenAsar, "Launch into OpenAsar", Ternary(currentDiscord != nil, "Launch into OpenAsar", "Land/Launch into OpenAsar"))).
+						g.Button(Ternary(isOpenAsar, "Launch into OpenAsar", Ternary(hasSelection, "Launch into OpenAsar", "Land/Launch into OpenAsar"))).
 							OnClick(handleOpenAsar).
 							Size((w-40)/4, 50),
 						Tooltip("Manage OpenAsar"),
 					),
 			),
 		),
+		g.Row(
+			g.Checkbox("Dry run", &dryRunMode),
+			Tooltip("Only show what the buttons above would rename, delete, write, download and run, without changing anything"),
+		),
 
+		g.Dummy(0, 10),
+		g.Style().SetFontSize(20).To(
+			g.RangeBuilder("Downloads", runningDownloads(), func(i int, v any) g.Widget {
+				p := v.(DownloadProgress)
+				return g.ProgressBar(p.Fraction()).Overlay(p.String()).Size(w, 30)
+			}),
+		),
+
+		g.Custom(openPendingPopups),
 		InfoModal("#patched", "You're on Venticord!", "Close Discord if it's open..\n"+
 			"Then, start it and verify Venticord installed successfully by looking for its category in Discord Settings!"),
 		InfoModal("#unpatched", "Goodbye!", "It's very sad to see you go. What happened?"),
@@ -523,11 +1013,25 @@ func renderInstaller() g.Widget {
 		InfoModal("#openasar-unpatched", "Successfully Uninstalled OpenAsar", "If Discord is still open, fully close it first. Then start it again and it should be back to stock!"),
 		InfoModal("#invalid-custom-location", "Invalid Location", "The specified location is not a valid Discord install. Make sure you select the base folder."),
 		InfoModal("#modal"+strconv.Itoa(modalId), modalTitle, modalMessage),
+		ChangelogModal(w),
+		LogViewerModal(w),
+		JournalModal(),
 	}
 
 	return layout
 }
 
+// go can you give me []any? part 2
+func runningDownloads() []any {
+	var running []any
+	for _, p := range downloadTracker.List() {
+		if !p.Done {
+			running = append(running, p)
+		}
+	}
+	return running
+}
+
 func renderErrorCard(col color.Color, message string, height float32) g.Widget {
 	return g.Style().
 		SetColor(g.StyleColorChildBg, col).
@@ -549,18 +1053,28 @@ func renderErrorCard(col color.Color, message string, height float32) g.Widget {
 
 func loop() {
 	g.PushWindowPadding(48, 48)
+	defer g.PopStyle()
+
+	// A dry run swaps out the state rendered below until it's done
+	if !StateLock.TryRLock() {
+		g.SingleWindow().Layout(
+			g.Align(g.AlignCenter).To(
+				g.Style().SetFontSize(30).To(
+					g.Label("Working out what would be changed..."),
+				),
+			),
+		)
+		return
+	}
+	defer StateLock.RUnlock()
 
 	g.SingleWindow().
 		RegisterKeyboardShortcuts(
 			g.WindowShortcut{Key: g.KeyUp, Callback: func() {
-				if radioIdx > 0 {
-					radioIdx--
-				}
+				moveSelection(-1)
 			}},
 			g.WindowShortcut{Key: g.KeyDown, Callback: func() {
-				if radioIdx < customChoiceIdx {
-					radioIdx++
-				}
+				moveSelection(1)
 			}},
 		).
 		Layout(
@@ -573,13 +1087,37 @@ func loop() {
 			g.Dummy(0, 20),
 			g.Style().SetFontSize(20).To(
 				g.Row(
-					g.Label(Ternary(IsDevInstall, "VentiDev: ", "Files will be downloaded to: ")+FilesDir),
+					g.Label(Ternary(IsDevInstall, "VentiDev: "+FilesDir, "Files will be downloaded to: "+VersionsDir)),
 					g.Style().
 						SetColor(g.StyleColorButton, DiscordBlue).
 						SetStyle(g.StyleVarFramePadding, 4, 4).
 						To(
 							g.Button("Open Directory").OnClick(func() {
-								g.OpenURL("file://" + FilesDir)
+								g.OpenURL("file://" + Ternary(IsDevInstall, FilesDir, VersionsDir))
+							}),
+						),
+					g.Style().
+						SetColor(g.StyleColorButton, DiscordBlue).
+						SetStyle(g.StyleVarFramePadding, 4, 4).
+						SetDisabled(isBusy).
+						To(
+							g.Button("Check integrity").OnClick(handleCheckIntegrity),
+						),
+					Tooltip("Check that no Venticord files were changed or deleted and repair them if needed"),
+					g.Style().
+						SetColor(g.StyleColorButton, DiscordBlue).
+						SetStyle(g.StyleVarFramePadding, 4, 4).
+						SetDisabled(isBusy).
+						To(
+							g.Button("Support report").OnClick(handleDoctor),
+						),
+					Tooltip("Save a report about your installs and network to attach when asking for help"),
+					g.Style().
+						SetColor(g.StyleColorButton, DiscordBlue).
+						SetStyle(g.StyleVarFramePadding, 4, 4).
+						To(
+							g.Button("Logs").OnClick(func() {
+								openPopup("#logs")
 							}),
 						),
 				),
@@ -588,18 +1126,84 @@ func loop() {
 				}, nil},
 				g.Dummy(0, 10),
 				g.Label("Installer Version: "+InstallerTag+" ("+InstallerGitHash+")"+Ternary(IsInstallerOutdated, " - VERY OUTDATED", "")),
-				g.Label("Local Ven(ti)cord Version: "+InstalledHash),
+				&CondWidget{InstalledManifest != nil, func() g.Widget {
+					return g.Row(
+						g.Label("Local Ven(ti)cord Version: "+InstalledHash+" (installed "+FormatAge(InstalledManifest.InstalledAt)+")"),
+						Tooltip("Installed from "+InstalledManifest.Source+" by installer "+InstalledManifest.InstallerVersion),
+					)
+				}, func() g.Widget {
+					return g.Label("Local Ven(ti)cord Version: " + InstalledHash)
+				}},
+				&CondWidget{len(installedVersions) > 1, func() g.Widget {
+					return g.Row(
+						g.Label("Installed Versions:"),
+						g.Style().
+							SetDisabled(isBusy).
+							To(
+								g.Combo("##versions", installedVersions[versionIdx], installedVersions, &versionIdx).Size(300),
+							),
+						g.Style().
+							SetColor(g.StyleColorButton, DiscordBlue).
+							SetStyle(g.StyleVarFramePadding, 4, 4).
+							SetDisabled(isBusy).
+							To(
+								g.Button("Switch").OnClick(handleSwitchVersion),
+							),
+						Tooltip("Use this version for all patched installs, no download needed"),
+					)
+				}, nil},
 				&CondWidget{
 					GithubError == nil,
 					func() g.Widget {
 						if IsDevInstall {
 							return g.Label("Not updating Venticord due to being in Ventidev Installer")
 						}
-						return g.Label("Latest Venticord Version: " + LatestHash)
+						return g.Row(
+							g.Label(Ternary(PinnedTag != "", "Pinned Venticord Version ("+PinnedTag+"): ", "Latest Venticord Version: ")+LatestHash),
+							&CondWidget{ReleaseData.IsStale, func() g.Widget {
+								return g.Style().SetColor(g.StyleColorText, DiscordYellow).To(
+									g.Label("(offline, cached " + FormatAge(ReleaseData.FetchedAt) + ")"),
+								)
+							}, nil},
+							&CondWidget{LatestHash != "Unknown", func() g.Widget {
+								return g.Style().
+									SetColor(g.StyleColorButton, DiscordBlue).
+									SetStyle(g.StyleVarFramePadding, 4, 4).
+									To(
+										g.Button("Changelog").OnClick(handleChangelog),
+									)
+							}, nil},
+							Tooltip("See what changed before updating"),
+						)
 					}, func() g.Widget {
 						return renderErrorCard(DiscordRed, "Failed to fetch Info from GitHub: "+GithubError.Error(), 40)
 					},
 				},
+				&CondWidget{!IsDevInstall, func() g.Widget {
+					return g.Row(
+						g.Label("Pin Release:"),
+						g.InputText(&pinTagInput).Hint("Release tag").Size(300),
+						g.Style().
+							SetColor(g.StyleColorButton, DiscordBlue).
+							SetStyle(g.StyleVarFramePadding, 4, 4).
+							To(
+								g.Button("Pin").OnClick(func() {
+									handlePin(pinTagInput)
+								}),
+							),
+						Tooltip("Always install this release instead of the latest one, even when repairing"),
+						&CondWidget{PinnedTag != "", func() g.Widget {
+							return g.Style().
+								SetColor(g.StyleColorButton, DiscordRed).
+								SetStyle(g.StyleVarFramePadding, 4, 4).
+								To(
+									g.Button("Unpin").OnClick(func() {
+										handlePin("")
+									}),
+								)
+						}, nil},
+					)
+				}, nil},
 				&CondWidget{
 					IsInstallerOutdated,
 					func() g.Widget {
@@ -615,6 +1219,4 @@ func loop() {
 				elseWidget: renderInstaller,
 			},
 		)
-
-	g.PopStyle()
 }
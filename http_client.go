@@ -0,0 +1,93 @@
+/*
+ * SPDX-License-Identifier: GPL-3.0
+ * Vencord Installer, a cross platform gui/cli app for installing Vencord
+ * Copyright (c) 2023 Vendicated and Vencord contributors
+ */
+
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"golang.org/x/net/http/httpproxy"
+	"net/http"
+	"net/url"
+	"os"
+)
+
+// HttpClient is used for every request, so proxy and certificate settings apply everywhere
+var HttpClient = http.DefaultClient
+
+// ProxyOverride is the proxy set with ConfigureHttpClient, or empty if HTTP_PROXY and HTTPS_PROXY are used
+var ProxyOverride string
+
+// GithubToken authenticates requests to the GitHub API, which raises the rate limit from 60 to 5000 requests per hour
+var GithubToken = os.Getenv("GITHUB_TOKEN")
+
+const GithubApiHost = "api.github.com"
+
+// NewRequest creates a GET request with our User-Agent. Only requests to the GitHub API get the GithubToken,
+// it is never sent to mirrors or download hosts
+func NewRequest(ctx context.Context, url string) (*http.Request, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	req.Header.Set("User-Agent", UserAgent)
+	if GithubToken != "" && req.URL.Scheme == "https" && req.URL.Host == GithubApiHost {
+		req.Header.Set("Authorization", "Bearer "+GithubToken)
+	}
+	return req, nil
+}
+
+// ConfigureHttpClient sets up HttpClient. proxy overrides HTTP_PROXY and HTTPS_PROXY, NO_PROXY is still respected.
+// caBundle is a PEM file with certificates to trust in addition to the system ones, e.g. for TLS intercepting proxies
+func ConfigureHttpClient(proxy, caBundle string) error {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+
+	if proxy != "" {
+		u, err := url.Parse(proxy)
+		if err != nil || u.Host == "" {
+			return errors.New("Invalid proxy url '" + proxy + "'")
+		}
+
+		LogInfo("Using proxy", u.Redacted())
+		// Only HTTP_PROXY and HTTPS_PROXY are replaced, so NO_PROXY works the same as without the flag
+		ProxyOverride = proxy
+		config := httpproxy.FromEnvironment()
+		config.HTTPProxy = proxy
+		config.HTTPSProxy = proxy
+		proxyFunc := config.ProxyFunc()
+		transport.Proxy = func(req *http.Request) (*url.URL, error) {
+			return proxyFunc(req.URL)
+		}
+	} else {
+		ProxyOverride = ""
+		transport.Proxy = http.ProxyFromEnvironment
+	}
+
+	if caBundle != "" {
+		pem, err := os.ReadFile(caBundle)
+		if err != nil {
+			return errors.New("Failed to read CA bundle: " + err.Error())
+		}
+
+		pool, err := x509.SystemCertPool()
+		if err != nil {
+			LogWarn("Failed to load system certificates, only trusting", caBundle+":", err)
+			pool = x509.NewCertPool()
+		}
+		if !pool.AppendCertsFromPEM(pem) {
+			return errors.New("CA bundle " + caBundle + " contains no certificates")
+		}
+
+		LogInfo("Trusting certificates from", caBundle)
+		transport.TLSClientConfig = &tls.Config{RootCAs: pool}
+	}
+
+	HttpClient = &http.Client{Transport: transport}
+	return nil
+}
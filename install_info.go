@@ -0,0 +1,143 @@
+/*
+ * SPDX-License-Identifier: GPL-3.0
+ * Vencord Installer, a cross platform gui/cli app for installing Vencord
+ * Copyright (c) 2023 Vendicated and Vencord contributors
+ */
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	path "path/filepath"
+	"strings"
+)
+
+// InstallInfo is the machine readable state of a DiscordInstall
+type InstallInfo struct {
+	Path             string `json:"path"`
+	Branch           string `json:"branch"`
+	IsPatched        bool   `json:"isPatched"`
+	IsFlatpak        bool   `json:"isFlatpak"`
+	IsSystemElectron bool   `json:"isSystemElectron"`
+	IsOpenAsar       bool   `json:"isOpenAsar"`
+	// Discord version from build_info.json, empty if unknown
+	AppVersion string `json:"appVersion,omitempty"`
+	// Venticord version loaded by the install's index.js, empty if not patched or unknown
+	VenticordVersion string `json:"venticordVersion,omitempty"`
+}
+
+func (di *DiscordInstall) Info() InstallInfo {
+	return InstallInfo{
+		Path:             di.path,
+		Branch:           di.branch,
+		IsPatched:        di.isPatched,
+		IsFlatpak:        di.isFlatpak,
+		IsSystemElectron: di.isSystemElectron,
+		IsOpenAsar:       di.IsOpenAsar(),
+		AppVersion:       di.AppVersion(),
+		VenticordVersion: di.VenticordVersion(),
+	}
+}
+
+// AppVersion reads the Discord version from build_info.json next to the app folder
+func (di *DiscordInstall) AppVersion() string {
+	if di.isSystemElectron {
+		// Packaged without build info
+		return ""
+	}
+
+	b, err := DiscordFS.ReadFile(path.Join(di.appPath, "..", "build_info.json"))
+	if err != nil {
+		return ""
+	}
+
+	var buildInfo struct {
+		Version string `json:"version"`
+	}
+	_ = json.Unmarshal(b, &buildInfo)
+	return buildInfo.Version
+}
+
+// VenticordVersion returns the version of the patcher.js the install's index.js loads
+func (di *DiscordInstall) VenticordVersion() string {
+	if !di.isPatched {
+		return ""
+	}
+	dir := di.patchDir()
+	if dir == "" {
+		return ""
+	}
+
+	b, err := DiscordFS.ReadFile(path.Join(dir, "index.js"))
+	if err != nil {
+		return ""
+	}
+
+	// index.js is require("<path to patcher.js>")
+	js := strings.TrimSpace(string(b))
+	if !strings.HasPrefix(js, "require(") || !strings.HasSuffix(js, ")") {
+		return ""
+	}
+	var patcher string
+	if err = json.Unmarshal([]byte(js[len("require("):len(js)-1]), &patcher); err != nil {
+		return ""
+	}
+
+	if manifest, err := ReadInstallManifest(path.Dir(patcher)); err == nil {
+		return manifest.Version
+	}
+	return ReadPatcherHash(patcher)
+}
+
+// InstallResult is the outcome of an action on one install
+type InstallResult struct {
+	Install InstallInfo `json:"install"`
+	Ok      bool        `json:"ok"`
+	Error   string      `json:"error,omitempty"`
+}
+
+// ForEachInstall runs fn on every install, even if it fails on some of them.
+// The returned error wraps the first failure if there was any
+func ForEachInstall(installs []*DiscordInstall, action string, fn func(di *DiscordInstall) error) ([]InstallResult, error) {
+	results := make([]InstallResult, len(installs))
+	var firstErr error
+	failed := 0
+
+	for i, di := range installs {
+		err := fn(di)
+		if err != nil {
+			LogWarn("Failed to", action, di.path+":", err)
+			failed++
+			if firstErr == nil {
+				firstErr = err
+			}
+		}
+
+		results[i] = InstallResult{Install: di.Info(), Ok: err == nil}
+		if err != nil {
+			results[i].Error = err.Error()
+		}
+	}
+
+	switch {
+	case failed == 0:
+		return results, nil
+	case len(installs) == 1:
+		return results, firstErr
+	default:
+		return results, fmt.Errorf("Failed to %s %d of %d installs. The first failed because of: %w", action, failed, len(installs), firstErr)
+	}
+}
+
+// SummarizeResults describes the results line by line
+func SummarizeResults(results []InstallResult) string {
+	lines := make([]string, len(results))
+	for i, res := range results {
+		lines[i] = Ternary(res.Ok, "[OK] ", "[FAILED] ") + res.Install.Branch + " (" + res.Install.Path + ")"
+		if !res.Ok {
+			lines[i] += ": " + res.Error
+		}
+	}
+	return strings.Join(lines, "\n")
+}
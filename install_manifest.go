@@ -0,0 +1,143 @@
+/*
+ * SPDX-License-Identifier: GPL-3.0
+ * Vencord Installer, a cross platform gui/cli app for installing Vencord
+ * Copyright (c) 2023 Vendicated and Vencord contributors
+ */
+
+package main
+
+import (
+	"bufio"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"os"
+	path "path/filepath"
+	"strings"
+	"time"
+)
+
+// InstallManifestName is written into every installed version folder, describing where its files came from
+const InstallManifestName = "install.json"
+
+type InstallManifest struct {
+	Version string `json:"version"`
+	// Release JSON url, bundle or checkout the files were installed from
+	Source           string    `json:"source"`
+	InstalledAt      time.Time `json:"installedAt"`
+	InstallerVersion string    `json:"installerVersion"`
+	// sha256 (hex) of every installed file, keyed by file name. Empty for files symlinked from a dev checkout,
+	// as those change with every rebuild and only have to exist
+	Files map[string]string `json:"files"`
+}
+
+// InstalledManifest is the manifest of the active version, nil for installs made by older installers
+var InstalledManifest *InstallManifest
+
+// WriteInstallManifest records version, source and the digests of all files in dir
+func WriteInstallManifest(dir, version, source string) error {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return err
+	}
+
+	manifest := InstallManifest{
+		Version:          version,
+		Source:           source,
+		InstalledAt:      time.Now().UTC(),
+		InstallerVersion: InstallerTag + " (" + InstallerGitHash + ")",
+		Files:            make(map[string]string),
+	}
+	for _, entry := range entries {
+		name := entry.Name()
+		if name == InstallManifestName || IsPartialDownload(name) {
+			continue
+		}
+
+		file := path.Join(dir, name)
+		if entry.Type()&os.ModeSymlink != 0 {
+			if info, err := os.Stat(file); err == nil && info.Mode().IsRegular() {
+				manifest.Files[name] = ""
+			}
+			continue
+		}
+		if !entry.Type().IsRegular() {
+			continue
+		}
+
+		sum, err := hashFile(file)
+		if err != nil {
+			return err
+		}
+		manifest.Files[name] = hex.EncodeToString(sum)
+	}
+
+	b, err := json.MarshalIndent(manifest, "", "\t")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path.Join(dir, InstallManifestName), b, 0644)
+}
+
+func ReadInstallManifest(dir string) (*InstallManifest, error) {
+	b, err := os.ReadFile(path.Join(dir, InstallManifestName))
+	if err != nil {
+		return nil, err
+	}
+
+	var manifest InstallManifest
+	if err = json.Unmarshal(b, &manifest); err != nil {
+		return nil, errors.New("Failed to parse " + InstallManifestName + ": " + err.Error())
+	}
+	if !IsValidVersion(manifest.Version) {
+		return nil, errors.New(InstallManifestName + " has invalid version '" + manifest.Version + "'")
+	}
+	return &manifest, nil
+}
+
+// DetectInstalledVersion sets InstalledHash and InstalledManifest from the files in FilesDir.
+// Installs without a manifest fall back to the "// Vencord <hash>" header of patcher.js
+func DetectInstalledVersion() {
+	InstalledManifest = nil
+
+	manifest, err := ReadInstallManifest(FilesDir)
+	if err == nil {
+		InstalledManifest = manifest
+		InstalledHash = manifest.Version
+		LogInfo("Installed version is", InstalledHash, "from", manifest.Source)
+		return
+	}
+	if !errors.Is(err, os.ErrNotExist) {
+		LogWarn("Ignoring install manifest:", err)
+	}
+
+	if !ExistsFile(Patcher) {
+		return
+	}
+
+	LogInfo("Found existing Venticord Install without manifest. Checking for hash...")
+	if hash := ReadPatcherHash(Patcher); hash != "" {
+		InstalledHash = hash
+		LogInfo("Existing hash is", InstalledHash)
+	} else {
+		LogInfo("Didn't find hash")
+	}
+}
+
+// ReadPatcherHash reads the hash from the "// Vencord <hash>" header of a patcher.js. Empty if there is none
+func ReadPatcherHash(patcher string) string {
+	f, err := os.Open(patcher)
+	if err != nil {
+		return ""
+	}
+	//goland:noinspection GoUnhandledErrorResult
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	if scanner.Scan() {
+		if line := scanner.Text(); strings.HasPrefix(line, "// Vencord ") {
+			return line[11:]
+		}
+	}
+	return ""
+}
@@ -0,0 +1,62 @@
+//go:build linux
+
+/*
+ * SPDX-License-Identifier: GPL-3.0
+ * Vencord Installer, a cross platform gui/cli app for installing Vencord
+ * Copyright (c) 2023 Vendicated and Vencord contributors
+ */
+
+package main
+
+import (
+	"os"
+	path "path/filepath"
+	"reflect"
+	"testing"
+)
+
+// Files symlinked from a dev checkout are in the manifest, so deleting them from the checkout is noticed
+func TestManifestOfLinkedCheckout(t *testing.T) {
+	checkout, dir := t.TempDir(), t.TempDir()
+	for _, file := range DistFiles {
+		if err := os.WriteFile(path.Join(checkout, file), []byte(file), 0644); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.Symlink(path.Join(checkout, file), path.Join(dir, file)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.WriteFile(path.Join(dir, "package.json"), PackageJson, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := WriteInstallManifest(dir, "dev-abc1234", checkout); err != nil {
+		t.Fatal(err)
+	}
+	manifest, err := ReadInstallManifest(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(manifest.Files) != len(DistFiles)+1 {
+		t.Fatal("Expected the linked files and package.json in the manifest, got", manifest.Files)
+	}
+
+	prevFilesDir, prevManifest := FilesDir, InstalledManifest
+	t.Cleanup(func() { FilesDir, InstalledManifest = prevFilesDir, prevManifest })
+	FilesDir, InstalledManifest = dir, manifest
+
+	// Rebuilds change the linked files
+	if err = os.WriteFile(path.Join(checkout, "patcher.js"), []byte("rebuilt"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if broken := checkFiles(); len(broken) != 0 {
+		t.Fatal("Rebuilt checkout reported as broken:", broken)
+	}
+
+	if err = os.Remove(path.Join(checkout, "renderer.js")); err != nil {
+		t.Fatal(err)
+	}
+	if broken := checkFiles(); !reflect.DeepEqual(broken, []string{"renderer.js: missing"}) {
+		t.Fatal("Expected renderer.js to be missing, got", broken)
+	}
+}
@@ -0,0 +1,293 @@
+/*
+ * SPDX-License-Identifier: GPL-3.0
+ * Vencord Installer, a cross platform gui/cli app for installing Vencord
+ * Copyright (c) 2023 Vendicated and Vencord contributors
+ */
+
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"os"
+	path "path/filepath"
+	"strings"
+	"time"
+)
+
+const (
+	JournalRename     = "rename"
+	JournalWriteFiles = "write-files"
+	JournalDelete     = "delete"
+)
+
+// JournalDir holds the journals of running patches, BaseDir/journals
+var JournalDir string
+
+// JournalStep is a single change to a Discord install
+type JournalStep struct {
+	Action string `json:"action"`
+	// Only set for renames
+	From string `json:"from,omitempty"`
+	To   string `json:"to"`
+	// If an optional step fails, the steps before it are kept
+	Optional bool `json:"optional,omitempty"`
+	Done     bool `json:"done"`
+}
+
+// Journal is a write-ahead log of the steps of a patch or unpatch. It is saved before anything is changed and
+// updated after every step, so if the installer is killed midway, the next start can finish or undo what it did
+type Journal struct {
+	Operation string `json:"operation"`
+	// The folder containing app.asar
+	Dir       string        `json:"dir"`
+	StartedAt time.Time     `json:"startedAt"`
+	Steps     []JournalStep `json:"steps"`
+
+	file string
+}
+
+// NewJournal creates an empty journal for operation, "patch" or "unpatch", on the folder containing app.asar
+func NewJournal(operation, dir string) *Journal {
+	sum := sha256.Sum256([]byte(dir))
+	return &Journal{
+		Operation: operation,
+		Dir:       dir,
+		StartedAt: time.Now(),
+		file:      path.Join(JournalDir, hex.EncodeToString(sum[:8])+".json"),
+	}
+}
+
+func (j *Journal) Rename(from, to string) {
+	j.Steps = append(j.Steps, JournalStep{Action: JournalRename, From: from, To: to})
+}
+
+func (j *Journal) WriteFiles(dir string) {
+	j.Steps = append(j.Steps, JournalStep{Action: JournalWriteFiles, To: dir})
+}
+
+// Delete removes p. This is always optional, as it can't be undone
+func (j *Journal) Delete(p string) {
+	j.Steps = append(j.Steps, JournalStep{Action: JournalDelete, To: p, Optional: true})
+}
+
+func (j *Journal) String() string {
+	return strings.ToUpper(j.Operation[:1]) + j.Operation[1:] + "ing " + j.Dir + " was interrupted " + FormatAge(j.StartedAt)
+}
+
+func (j *Journal) save() error {
+	if dryRunPlan() != nil {
+		return nil
+	}
+
+	b, err := json.MarshalIndent(j, "", "\t")
+	if err != nil {
+		return err
+	}
+	if err = os.MkdirAll(JournalDir, 0755); err != nil {
+		return err
+	}
+
+	// Write to a temporary file first so a crash can't leave a half written journal behind
+	tmp := j.file + ".tmp"
+	if err = os.WriteFile(tmp, b, 0644); err != nil {
+		return err
+	}
+	if err = os.Rename(tmp, j.file); err != nil {
+		return err
+	}
+	// Otherwise a journal left by a sudo run can't be deleted by later runs
+	_ = FixOwnership(JournalDir)
+	return nil
+}
+
+func (j *Journal) remove() {
+	if dryRunPlan() != nil {
+		return
+	}
+	if err := os.Remove(j.file); err != nil && !errors.Is(err, os.ErrNotExist) {
+		LogWarn("Failed to delete journal", j.file+":", err)
+	}
+}
+
+// Run saves the journal and performs all steps. If a step fails, the ones already done are undone.
+// The journal is deleted once the install is in a consistent state again
+func (j *Journal) Run() error {
+	if err := j.save(); err != nil {
+		return errors.New("Failed to write journal: " + err.Error())
+	}
+
+	for i := range j.Steps {
+		step := &j.Steps[i]
+		err := step.run()
+		if err != nil && step.Optional {
+			LogWarn("Failed to", step.Action, step.To+":", err)
+			continue
+		}
+		if err != nil && i == 0 && !step.happened() {
+			// Nothing to undo
+			j.remove()
+			return err
+		}
+		if err != nil {
+			LogWarn("Failed to " + j.Operation + ". Undoing partial " + j.Operation)
+			if undoErr := j.undo(); undoErr != nil {
+				LogError("Failed to undo partial "+j.Operation+". This install is probably bricked. The installer will try again on the next start.", undoErr)
+			} else {
+				LogInfo("Successfully undid all changes")
+				j.remove()
+			}
+			return err
+		}
+
+		step.Done = true
+		if err = j.save(); err != nil {
+			LogWarn("Failed to update journal:", err)
+		}
+	}
+
+	j.remove()
+	return nil
+}
+
+// RollForward finishes an interrupted journal
+func (j *Journal) RollForward() error {
+	LogInfo("Finishing", j.Operation, "of", j.Dir)
+	for i := range j.Steps {
+		step := &j.Steps[i]
+		if step.Done || step.happened() {
+			continue
+		}
+
+		if err := step.run(); err != nil {
+			if step.Optional {
+				LogWarn("Failed to", step.Action, step.To+":", err)
+				continue
+			}
+			return err
+		}
+		step.Done = true
+		_ = j.save()
+	}
+
+	j.remove()
+	return nil
+}
+
+// RollBack undoes everything an interrupted journal did
+func (j *Journal) RollBack() error {
+	LogInfo("Undoing", j.Operation, "of", j.Dir)
+	if err := j.undo(); err != nil {
+		return err
+	}
+	j.remove()
+	return nil
+}
+
+// undo reverts all steps that are done, plus the one that was running if it already took effect
+func (j *Journal) undo() error {
+	last := len(j.Steps) - 1
+	for i, step := range j.Steps {
+		if !step.Done {
+			last = Ternary(step.happened() || step.Action == JournalWriteFiles, i, i-1)
+			break
+		}
+	}
+
+	for i := last; i >= 0; i-- {
+		step := &j.Steps[i]
+		if err := step.undo(); err != nil {
+			return err
+		}
+		step.Done = false
+		_ = j.save()
+	}
+	return nil
+}
+
+func (s *JournalStep) run() error {
+	switch s.Action {
+	case JournalRename:
+		LogInfo("Renaming", s.From, "to", s.To)
+		if err := DiscordFS.Rename(s.From, s.To); err != nil {
+			return CheckIfErrIsCauseItsBusyRn(err)
+		}
+	case JournalWriteFiles:
+		LogInfo("Writing files to", s.To)
+		return writeFiles(s.To)
+	case JournalDelete:
+		LogInfo("Deleting", s.To)
+		return DiscordFS.RemoveAll(s.To)
+	}
+	return nil
+}
+
+func (s *JournalStep) undo() error {
+	switch s.Action {
+	case JournalRename:
+		LogInfo("Renaming", s.To, "back to", s.From)
+		if err := DiscordFS.Rename(s.To, s.From); err != nil {
+			return CheckIfErrIsCauseItsBusyRn(err)
+		}
+	case JournalWriteFiles:
+		if err := IsSafeToDelete(s.To); err != nil {
+			return errors.New("Not deleting " + s.To + ": " + err.Error())
+		}
+		LogInfo("Deleting", s.To)
+		return DiscordFS.RemoveAll(s.To)
+	case JournalDelete:
+		if !ExistsDiscordFile(s.To) {
+			return errors.New(s.To + " was deleted already, so this can only be finished")
+		}
+	}
+	return nil
+}
+
+// happened checks whether a step that isn't marked as done took effect anyway, which happens if the
+// installer was killed right after it but before the journal was updated
+func (s *JournalStep) happened() bool {
+	switch s.Action {
+	case JournalRename:
+		return !ExistsDiscordFile(s.From) && ExistsDiscordFile(s.To)
+	case JournalDelete:
+		return !ExistsDiscordFile(s.To)
+	default:
+		// Writing files is repeated either way
+		return false
+	}
+}
+
+// PendingJournals returns the journals of patches that were interrupted
+func PendingJournals() []*Journal {
+	entries, err := os.ReadDir(JournalDir)
+	if err != nil {
+		if !errors.Is(err, os.ErrNotExist) {
+			LogWarn("Error during readdir "+JournalDir+":", err)
+		}
+		return nil
+	}
+
+	var journals []*Journal
+	for _, entry := range entries {
+		if entry.IsDir() || path.Ext(entry.Name()) != ".json" {
+			continue
+		}
+
+		file := path.Join(JournalDir, entry.Name())
+		b, err := os.ReadFile(file)
+		if err != nil {
+			LogWarn("Failed to read journal", file+":", err)
+			continue
+		}
+
+		j := &Journal{file: file}
+		if err = json.Unmarshal(b, j); err != nil || j.Operation == "" {
+			LogWarn("Ignoring invalid journal", file)
+			continue
+		}
+		journals = append(journals, j)
+	}
+	return journals
+}
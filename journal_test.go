@@ -0,0 +1,153 @@
+//go:build linux
+
+/*
+ * SPDX-License-Identifier: GPL-3.0
+ * Vencord Installer, a cross platform gui/cli app for installing Vencord
+ * Copyright (c) 2023 Vendicated and Vencord contributors
+ */
+
+package main
+
+import (
+	"errors"
+	"os"
+	"testing"
+)
+
+// useFaultFS wraps the MemFS in a FaultFS configured by f
+func useFaultFS(m *MemFS, f *FaultFS) *FaultFS {
+	f.FS = m
+	DiscordFS = f
+	return f
+}
+
+func assertNoJournals(t *testing.T) {
+	t.Helper()
+	if journals := PendingJournals(); len(journals) != 0 {
+		t.Fatal("Journal left behind:", journals[0])
+	}
+}
+
+func TestPatchRollsBackFailedRename(t *testing.T) {
+	m := useMemFS(t, systemElectronInstall)
+	di := parseFake(t, "/usr/lib/discord")
+
+	// The second rename is app.asar.unpacked, after app.asar was moved already
+	f := useFaultFS(m, &FaultFS{FailRename: 2})
+	err := di.patch()
+	if !errors.Is(err, ErrInjectedFault) {
+		t.Fatal("Expected injected fault, got", err)
+	}
+	// Two renames, then one to undo the first
+	if f.Renames() != 3 {
+		t.Fatal("Expected 3 renames, got", f.Renames())
+	}
+	assertLayout(t, m, systemElectronInstall)
+	assertNoJournals(t)
+}
+
+func TestUnpatchRollsBackFailedRename(t *testing.T) {
+	m := useMemFS(t, patchedNormalInstall)
+	di := parseFake(t, "/opt/Discord")
+
+	// The second rename moves _app.asar back, after the patch folder was moved to app.asar.tmp
+	useFaultFS(m, &FaultFS{FailRename: 2})
+	if err := di.unpatch(); !errors.Is(err, ErrInjectedFault) {
+		t.Fatal("Expected injected fault, got", err)
+	}
+	assertLayout(t, m, patchedNormalInstall)
+	assertNoJournals(t)
+}
+
+func TestUnpatchSystemElectronRollsBackFailedRename(t *testing.T) {
+	m := useMemFS(t, patchedSystemElectronInstall)
+	di := parseFake(t, "/usr/lib/discord")
+
+	// app.asar.unpacked is the third rename, so two have to be undone
+	useFaultFS(m, &FaultFS{FailRename: 3})
+	if err := di.unpatch(); !errors.Is(err, ErrInjectedFault) {
+		t.Fatal("Expected injected fault, got", err)
+	}
+	assertLayout(t, m, patchedSystemElectronInstall)
+	assertNoJournals(t)
+}
+
+func TestPatchBusyFile(t *testing.T) {
+	m := useMemFS(t, normalInstall)
+	di := parseFake(t, "/opt/Discord")
+
+	useFaultFS(m, &FaultFS{Busy: []string{"/opt/Discord/resources/app.asar"}})
+	if err := di.patch(); !errors.Is(err, ErrDiscordBusy) {
+		t.Fatal("Expected ErrDiscordBusy, got", err)
+	}
+	assertLayout(t, m, normalInstall)
+	assertNoJournals(t)
+}
+
+func TestUnpatchBusyFile(t *testing.T) {
+	m := useMemFS(t, patchedNormalInstall)
+	di := parseFake(t, "/opt/Discord")
+
+	// Fails on the second rename, so the first has to be undone
+	useFaultFS(m, &FaultFS{Busy: []string{"/opt/Discord/resources/_app.asar"}})
+	if err := di.unpatch(); !errors.Is(err, ErrDiscordBusy) {
+		t.Fatal("Expected ErrDiscordBusy, got", err)
+	}
+	assertLayout(t, m, patchedNormalInstall)
+	assertNoJournals(t)
+}
+
+func TestUnpatchPermissionDenied(t *testing.T) {
+	m := useMemFS(t, patchedNormalInstall)
+	di := parseFake(t, "/opt/Discord")
+
+	useFaultFS(m, &FaultFS{Denied: []string{"/opt/Discord/resources"}})
+	if err := di.unpatch(); !errors.Is(err, os.ErrPermission) {
+		t.Fatal("Expected permission error, got", err)
+	}
+	assertLayout(t, m, patchedNormalInstall)
+	assertNoJournals(t)
+}
+
+// interruptedPatch leaves a journal behind as if the installer was killed after the first rename of a patch
+func interruptedPatch(t *testing.T, m *MemFS) *Journal {
+	t.Helper()
+
+	dir := "/opt/Discord/resources"
+	j := NewJournal("patch", dir)
+	j.Rename(dir+"/app.asar", dir+"/_app.asar")
+	j.WriteFiles(dir + "/app.asar")
+	if err := j.save(); err != nil {
+		t.Fatal(err)
+	}
+	// Killed before the journal was updated
+	if err := m.Rename(dir+"/app.asar", dir+"/_app.asar"); err != nil {
+		t.Fatal(err)
+	}
+
+	journals := PendingJournals()
+	if len(journals) != 1 {
+		t.Fatal("Expected 1 pending journal, got", len(journals))
+	}
+	return journals[0]
+}
+
+func TestRollBackInterruptedPatch(t *testing.T) {
+	m := useMemFS(t, normalInstall)
+
+	if err := interruptedPatch(t, m).RollBack(); err != nil {
+		t.Fatal(err)
+	}
+	assertLayout(t, m, normalInstall)
+	assertNoJournals(t)
+}
+
+func TestRollForwardInterruptedPatch(t *testing.T) {
+	m := useMemFS(t, normalInstall)
+
+	if err := interruptedPatch(t, m).RollForward(); err != nil {
+		t.Fatal(err)
+	}
+	assertLayout(t, m, patchedNormalInstall)
+	assertNoJournals(t)
+}
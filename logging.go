@@ -0,0 +1,247 @@
+/*
+ * SPDX-License-Identifier: GPL-3.0
+ * Vencord Installer, a cross platform gui/cli app for installing Vencord
+ * Copyright (c) 2023 Vendicated and Vencord contributors
+ */
+
+package main
+
+import (
+	"fmt"
+	"os"
+	path "path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// LogLevel is how important a log message is. Everything is written to the log file,
+// but only messages at ConsoleLevel or above are printed
+type LogLevel int
+
+const (
+	LevelDebug LogLevel = iota
+	LevelInfo
+	LevelWarn
+	LevelError
+)
+
+func (l LogLevel) String() string {
+	switch l {
+	case LevelDebug:
+		return "DEBUG"
+	case LevelInfo:
+		return "INFO"
+	case LevelWarn:
+		return "WARN"
+	default:
+		return "ERROR"
+	}
+}
+
+const (
+	LogFileName = "installer.log"
+	// MaxLogFiles is how many log files are kept, the current one included. Every run starts a new one
+	MaxLogFiles = 5
+	// MaxLogSize starts a new log file mid-run once the current one gets this big
+	MaxLogSize = 5 << 20
+	// maxRecentLogs is how many messages are kept in memory for the GUI log viewer
+	maxRecentLogs = 2000
+)
+
+// ConsoleLevel is the lowest level printed to stdout. -verbose and -quiet change it
+var ConsoleLevel = LevelInfo
+
+// LogDir is where log files go, BaseDir/logs
+var LogDir string
+
+type LogEntry struct {
+	Time    time.Time
+	Level   LogLevel
+	Message string
+}
+
+func (e LogEntry) String() string {
+	return e.Time.Format("2006-01-02 15:04:05.000") + " " + fmt.Sprintf("%-5s", e.Level.String()) + " " + e.Message
+}
+
+var logger struct {
+	mu   sync.Mutex
+	file *os.File
+	size int64
+	// Logging starts long before BaseDir is known, so everything is kept until OpenLogFile
+	pending []LogEntry
+	recent  []LogEntry
+	// Console output is kept between HoldConsole and ReleaseConsole
+	consoleHeld bool
+	held        []LogEntry
+}
+
+// Log prints its arguments like fmt.Println if level is at least ConsoleLevel, and writes it to the log file
+func Log(level LogLevel, a ...any) {
+	entry := LogEntry{
+		Time:    time.Now(),
+		Level:   level,
+		Message: strings.TrimSuffix(fmt.Sprintln(a...), "\n"),
+	}
+
+	logger.mu.Lock()
+	defer logger.mu.Unlock()
+
+	if logger.consoleHeld {
+		logger.held = append(logger.held, entry)
+	} else if level >= ConsoleLevel {
+		// Not cached as -json swaps out os.Stdout
+		_, _ = fmt.Fprintln(os.Stdout, entry.Message)
+	}
+
+	logger.recent = append(logger.recent, entry)
+	if len(logger.recent) > maxRecentLogs {
+		logger.recent = logger.recent[len(logger.recent)-maxRecentLogs:]
+	}
+
+	if logger.file == nil {
+		if len(logger.pending) < maxRecentLogs {
+			logger.pending = append(logger.pending, entry)
+		}
+		return
+	}
+	writeLogEntry(entry)
+}
+
+// HoldConsole stops printing messages until ReleaseConsole, for when it isn't known yet where they go
+// or which ConsoleLevel applies. They are still written to the log file
+func HoldConsole() bool {
+	logger.mu.Lock()
+	defer logger.mu.Unlock()
+	logger.consoleHeld = true
+	return true
+}
+
+// ReleaseConsole prints the messages held since HoldConsole that are at ConsoleLevel or above
+func ReleaseConsole() {
+	logger.mu.Lock()
+	defer logger.mu.Unlock()
+
+	for _, entry := range logger.held {
+		if entry.Level >= ConsoleLevel {
+			_, _ = fmt.Fprintln(os.Stdout, entry.Message)
+		}
+	}
+	logger.held = nil
+	logger.consoleHeld = false
+}
+
+func LogDebug(a ...any) {
+	Log(LevelDebug, a...)
+}
+
+func LogInfo(a ...any) {
+	Log(LevelInfo, a...)
+}
+
+func LogWarn(a ...any) {
+	Log(LevelWarn, a...)
+}
+
+func LogError(a ...any) {
+	Log(LevelError, a...)
+}
+
+// RecentLogs returns the last messages logged, oldest first
+func RecentLogs() []LogEntry {
+	logger.mu.Lock()
+	defer logger.mu.Unlock()
+	return append([]LogEntry(nil), logger.recent...)
+}
+
+// LogFile returns the path of the current log file, or nothing if there is none
+func LogFile() string {
+	logger.mu.Lock()
+	defer logger.mu.Unlock()
+	if logger.file == nil {
+		return ""
+	}
+	return logger.file.Name()
+}
+
+// LogFiles returns the paths of all log files that exist, newest first
+func LogFiles() []string {
+	var files []string
+	for i := 0; i < MaxLogFiles; i++ {
+		if file := logFilePath(i); ExistsFile(file) {
+			files = append(files, file)
+		}
+	}
+	return files
+}
+
+// OpenLogFile starts a new log file in dir, keeping the ones of the last MaxLogFiles-1 runs,
+// and writes everything logged so far to it
+func OpenLogFile(dir string) error {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+
+	logger.mu.Lock()
+	LogDir = dir
+	err := openLogFile()
+	if err == nil {
+		for _, entry := range logger.pending {
+			writeLogEntry(entry)
+		}
+		logger.pending = nil
+	}
+	logger.mu.Unlock()
+
+	if err != nil {
+		return err
+	}
+	_ = FixOwnership(dir)
+	return nil
+}
+
+func logFilePath(i int) string {
+	if i == 0 {
+		return path.Join(LogDir, LogFileName)
+	}
+	return path.Join(LogDir, strings.TrimSuffix(LogFileName, ".log")+"."+strconv.Itoa(i)+".log")
+}
+
+// openLogFile rotates the log files and opens a new one. logger.mu must be held
+func openLogFile() error {
+	if logger.file != nil {
+		_ = logger.file.Close()
+		logger.file = nil
+	}
+
+	_ = os.Remove(logFilePath(MaxLogFiles - 1))
+	for i := MaxLogFiles - 2; i >= 0; i-- {
+		_ = os.Rename(logFilePath(i), logFilePath(i+1))
+	}
+
+	f, err := os.OpenFile(logFilePath(0), os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0644)
+	if err != nil {
+		return err
+	}
+	logger.file = f
+	logger.size = 0
+	return nil
+}
+
+// writeLogEntry appends entry to the log file. logger.mu must be held
+func writeLogEntry(entry LogEntry) {
+	if logger.size >= MaxLogSize {
+		if err := openLogFile(); err != nil {
+			_, _ = fmt.Fprintln(os.Stderr, "Failed to rotate log file:", err)
+			return
+		}
+	}
+
+	n, err := logger.file.WriteString(entry.String() + "\n")
+	logger.size += int64(n)
+	if err != nil {
+		_, _ = fmt.Fprintln(os.Stderr, "Failed to write to log file:", err)
+	}
+}
@@ -9,29 +9,20 @@ package main
 import (
 	"bytes"
 	"errors"
-	"fmt"
-	"io"
-	"net/http"
-	"os"
 	path "path/filepath"
-	"strconv"
 )
 
 const OpenAsarDownloadLink = "https://github.com/GooseMod/OpenAsar/releases/download/nightly/app.asar"
 
-func FindAsarFile(dir string) (*os.File, error) {
+// FindAsarFile returns the path of the asar Discord loads in dir
+func FindAsarFile(dir string) (string, error) {
 	for _, file := range []string{"app.asar", "_app.asar"} {
-		f, err := os.Open(path.Join(dir, file))
-		if err != nil {
-			continue
+		p := path.Join(dir, file)
+		if stats, err := DiscordFS.Stat(p); err == nil && !stats.IsDir() {
+			return p, nil
 		}
-		stats, err := f.Stat()
-		if err == nil && !stats.IsDir() {
-			return f, nil
-		}
-		_ = f.Close()
 	}
-	return nil, errors.New("Install at " + dir + " has no asar file")
+	return "", errors.New("Install at " + dir + " has no asar file")
 }
 
 func (di *DiscordInstall) IsOpenAsar() (retBool bool) {
@@ -40,20 +31,19 @@ func (di *DiscordInstall) IsOpenAsar() (retBool bool) {
 	}
 
 	defer func() {
-		fmt.Println("Checking if", di.path, "is using OpenAsar:", retBool)
+		LogDebug("Checking if", di.path, "is using OpenAsar:", retBool)
 		di.isOpenAsar = &retBool
 	}()
 
 	asarFile, err := FindAsarFile(path.Join(di.appPath, ".."))
 	if err != nil {
-		fmt.Println(err)
+		LogWarn(err)
 		return false
 	}
 
-	b, err := io.ReadAll(asarFile)
-	_ = asarFile.Close()
+	b, err := DiscordFS.ReadFile(asarFile)
 	if err != nil {
-		fmt.Println(err)
+		LogWarn(err)
 		return false
 	}
 
@@ -72,26 +62,19 @@ func (di *DiscordInstall) InstallOpenAsar() error {
 	if err != nil {
 		return err
 	}
-	_ = asarFile.Close()
-
-	if err = os.Rename(asarFile.Name(), path.Join(dir, "app.asar.original")); err != nil {
-		return err
-	}
 
-	res, err := http.Get(OpenAsarDownloadLink)
-	if err != nil {
-		return err
-	} else if res.StatusCode >= 300 {
-		return errors.New("Failed to fetch OpenAsar - " + strconv.Itoa(res.StatusCode) + ": " + res.Status)
-	}
-
-	outFile, err := os.Create(asarFile.Name())
-	if err != nil {
+	originalAsar := path.Join(dir, "app.asar.original")
+	if err = DiscordFS.Rename(asarFile, originalAsar); err != nil {
 		return err
 	}
 
-	if _, err = io.Copy(outFile, res.Body); err != nil {
-		return err
+	if err = DownloadInto("OpenAsar", OpenAsarDownloadLink, asarFile); err != nil {
+		LogWarn("Failed to download OpenAsar. Restoring original asar")
+		RemovePartialDownload(asarFile)
+		if innerErr := DiscordFS.Rename(originalAsar, asarFile); innerErr != nil {
+			LogError("Failed to restore original asar. Reinstall Discord", innerErr)
+		}
+		return errors.New("Failed to fetch OpenAsar - " + err.Error())
 	}
 
 	di.isOpenAsar = Ptr(true)
@@ -100,10 +83,10 @@ func (di *DiscordInstall) InstallOpenAsar() error {
 
 func (di *DiscordInstall) UninstallOpenAsar() error {
 	PreparePatch(di)
-	
+
 	dir := path.Join(di.appPath, "..")
 	originalAsar := path.Join(dir, "app.asar.original")
-	if !ExistsFile(originalAsar) {
+	if !ExistsDiscordFile(originalAsar) {
 		return errors.New("No app.asar.original. Reinstall Discord")
 	}
 
@@ -111,9 +94,8 @@ func (di *DiscordInstall) UninstallOpenAsar() error {
 	if err != nil {
 		return err
 	}
-	_ = asarFile.Close()
 
-	if err = os.Rename(originalAsar, asarFile.Name()); err != nil {
+	if err = DiscordFS.Rename(originalAsar, asarFile); err != nil {
 		return err
 	}
 
@@ -9,7 +9,6 @@ package main
 import (
 	"encoding/json"
 	"errors"
-	"fmt"
 	"github.com/ProtonMail/go-appdir"
 	"os"
 	"os/exec"
@@ -18,10 +17,17 @@ import (
 )
 
 var BaseDir string
+
+// BaseDirSource says how BaseDir was chosen, for the doctor report
+var BaseDirSource string
 var FilesDir string
 var FilesDirErr error
 var Patcher string
 
+// PatchedInstallsFile lists every install we patched, one path per line. Installs patched at a custom location
+// aren't found by FindDiscords, but still have to load the active version
+var PatchedInstallsFile string
+
 var PackageJson = []byte(`{
 	"name": "discord",
 	"main": "index.js"
@@ -30,24 +36,48 @@ var PackageJson = []byte(`{
 
 func init() {
 	if dir := os.Getenv("VENCORD_USER_DATA_DIR"); dir != "" {
-		fmt.Println("Using VENCORD_USER_DATA_DIR")
+		LogDebug("Using VENCORD_USER_DATA_DIR")
+		BaseDirSource = "VENCORD_USER_DATA_DIR"
 		BaseDir = dir
 	} else if dir = os.Getenv("DISCORD_USER_DATA_DIR"); dir != "" {
-		fmt.Println("Using DISCORD_USER_DATA_DIR/../VencordData")
+		LogDebug("Using DISCORD_USER_DATA_DIR/../VencordData")
+		BaseDirSource = "DISCORD_USER_DATA_DIR/../VencordData"
 		BaseDir = path.Join(dir, "..", "VencordData")
 	} else {
-		fmt.Println("Using UserConfig")
+		LogDebug("Using UserConfig")
+		BaseDirSource = "UserConfig"
 		BaseDir = appdir.New("Vencord").UserConfig()
 	}
-	FilesDir = path.Join(BaseDir, "dist")
-	if !ExistsFile(FilesDir) {
-		FilesDirErr = os.MkdirAll(FilesDir, 0755)
+	if err := OpenLogFile(path.Join(BaseDir, "logs")); err != nil {
+		LogWarn("Failed to open log file:", err)
+	}
+	VersionsDir = path.Join(BaseDir, "versions")
+	JournalDir = path.Join(BaseDir, "journals")
+	ActiveVersionFile = path.Join(BaseDir, "active-version")
+	PinnedTagFile = path.Join(BaseDir, "pinned-version")
+	CacheDir = path.Join(BaseDir, "cache")
+	ConfigFile = path.Join(BaseDir, "config.json")
+	PatchedInstallsFile = path.Join(BaseDir, "patched-installs")
+	LoadConfig()
+	if b, err := os.ReadFile(PinnedTagFile); err == nil {
+		PinnedTag = strings.TrimSpace(string(b))
+	}
+	if !ExistsFile(VersionsDir) {
+		FilesDirErr = os.MkdirAll(VersionsDir, 0755)
 		if FilesDirErr != nil {
-			fmt.Println("Failed to create", FilesDir, FilesDirErr)
+			LogWarn("Failed to create", VersionsDir, FilesDirErr)
 		} else {
 			FilesDirErr = FixOwnership(BaseDir)
 		}
 	}
+
+	IsDevInstall = os.Getenv("VENCORD_DEV_INSTALL") == "1"
+	if hash := ReadActiveVersion(); hash != "" && !IsDevInstall {
+		FilesDir = path.Join(VersionsDir, hash)
+	} else {
+		// Builds used to live in dist before there were versions. Dev installs still expect their files there
+		FilesDir = path.Join(BaseDir, "dist")
+	}
 	Patcher = path.Join(FilesDir, "patcher.js")
 }
 
@@ -64,7 +94,7 @@ type DiscordInstall struct {
 // IsSafeToDelete returns nil if path is safe to delete.
 // In other cases, the returned error should give more info
 func IsSafeToDelete(path string) error {
-	files, err := os.ReadDir(path)
+	files, err := DiscordFS.ReadDir(path)
 	if err != nil {
 		if errors.Is(err, os.ErrNotExist) {
 			err = nil
@@ -81,68 +111,184 @@ func IsSafeToDelete(path string) error {
 }
 
 func writeFiles(dir string) error {
-	if err := os.RemoveAll(dir); err != nil {
+	if err := DiscordFS.RemoveAll(dir); err != nil {
 		return err
 	}
 
-	if err := os.Mkdir(dir, 0755); err != nil {
+	if err := DiscordFS.Mkdir(dir, 0755); err != nil {
 		return err
 	}
 
-	if err := os.WriteFile(path.Join(dir, "package.json"), PackageJson, 0644); err != nil {
+	if err := DiscordFS.WriteFile(path.Join(dir, "package.json"), PackageJson, 0644); err != nil {
 		return err
 	}
 
 	patcherPath, _ := json.Marshal(Patcher)
-	return os.WriteFile(path.Join(dir, "index.js"), []byte("require("+string(patcherPath)+")"), 0644)
+	return DiscordFS.WriteFile(path.Join(dir, "index.js"), []byte("require("+string(patcherPath)+")"), 0644)
 }
 
-func patchRenames(dir string, isSystemElectron bool) (err error) {
-	appAsar := path.Join(dir, "app.asar")
-	_appAsar := path.Join(dir, "_app.asar")
+// patchDir returns the folder containing our index.js if this install is patched
+func (di *DiscordInstall) patchDir() string {
+	if di.isSystemElectron {
+		return Ternary(di.isPatched, path.Join(di.path, "app.asar"), "")
+	}
+	if asarDir := path.Join(di.appPath, "..", "app.asar"); IsDiscordDirectory(asarDir) {
+		return asarDir
+	}
+	if ExistsDiscordFile(di.appPath) {
+		return di.appPath
+	}
+	return ""
+}
+
+// readPatchedInstallsFile returns the paths in PatchedInstallsFile
+func readPatchedInstallsFile() []string {
+	b, err := os.ReadFile(PatchedInstallsFile)
+	if err != nil {
+		if !errors.Is(err, os.ErrNotExist) {
+			LogWarn("Failed to read", PatchedInstallsFile+":", err)
+		}
+		return nil
+	}
+
+	var paths []string
+	for _, line := range strings.Split(string(b), "\n") {
+		if line = strings.TrimSpace(line); line != "" {
+			paths = append(paths, line)
+		}
+	}
+	return paths
+}
+
+// rememberPatchedInstall adds p to PatchedInstallsFile, or removes it if it's no longer patched
+func rememberPatchedInstall(p string, isPatched bool) {
+	if dryRunPlan() != nil {
+		return
+	}
+
+	p = path.Clean(p)
+	var paths []string
+	for _, recorded := range readPatchedInstallsFile() {
+		if recorded != p {
+			paths = append(paths, recorded)
+		}
+	}
+	if isPatched {
+		paths = append(paths, p)
+	}
+
+	content := ""
+	if len(paths) != 0 {
+		content = strings.Join(paths, "\n") + "\n"
+	}
+	if err := os.WriteFile(PatchedInstallsFile, []byte(content), 0644); err != nil {
+		LogWarn("Failed to update", PatchedInstallsFile+":", err)
+		return
+	}
+	_ = FixOwnership(PatchedInstallsFile)
+}
+
+// PatchedInstalls returns all patched installs, both the ones FindDiscords found and the ones in PatchedInstallsFile
+func PatchedInstalls() []*DiscordInstall {
+	var installs []*DiscordInstall
+	seen := make(map[string]bool)
+	for _, discord := range discords {
+		di := discord.(*DiscordInstall)
+		seen[path.Clean(di.path)] = true
+		if di.isPatched {
+			installs = append(installs, di)
+		}
+	}
+
+	for _, p := range readPatchedInstallsFile() {
+		if seen[p] {
+			continue
+		}
+		seen[p] = true
+		if di := ParseDiscord(p, ""); di != nil && di.isPatched {
+			installs = append(installs, di)
+		}
+	}
+	return installs
+}
+
+// loadedPatcher returns the patcher.js the index.js of this install loads, or "" if that can't be told
+func (di *DiscordInstall) loadedPatcher() string {
+	dir := di.patchDir()
+	if dir == "" {
+		return ""
+	}
+
+	b, err := DiscordFS.ReadFile(path.Join(dir, "index.js"))
+	if err != nil {
+		return ""
+	}
+
+	code := strings.TrimSpace(string(b))
+	if !strings.HasPrefix(code, "require(") || !strings.HasSuffix(code, ")") {
+		return ""
+	}
+	var patcher string
+	if err = json.Unmarshal([]byte(code[len("require("):len(code)-1]), &patcher); err != nil {
+		return ""
+	}
+	return patcher
+}
 
-	var renamesDone [][]string
-	defer func() {
-		if err != nil && len(renamesDone) > 0 {
-			fmt.Println("Failed to patch. Undoing partial patch")
-			for _, rename := range renamesDone {
-				if innerErr := os.Rename(rename[1], rename[0]); innerErr != nil {
-					fmt.Println("Failed to undo partial patch... that's not good. This install is probably bricked.", innerErr)
-				} else {
-					fmt.Println("Successfully undid all changes")
-				}
+// RepointPatchedInstalls makes all patched installs load the active build
+func RepointPatchedInstalls() error {
+	var failed []string
+	for _, di := range PatchedInstalls() {
+		dir := di.patchDir()
+		if dir == "" {
+			continue
+		}
+		if err := IsSafeToDelete(dir); err != nil {
+			LogWarn("Not touching", dir+":", err)
+			continue
+		}
+
+		LogInfo("Pointing", di.path, "at", Patcher)
+		if err := writeFiles(dir); err != nil {
+			LogWarn("Failed to update", dir+":", err)
+			failed = append(failed, di.path+": "+err.Error())
+			continue
+		}
+
+		if di.isFlatpak {
+			if err := di.grantFlatpakAccess(); err != nil {
+				LogWarn(err)
+				failed = append(failed, di.path+": "+err.Error())
 			}
 		}
-	}()
+	}
 
-	fmt.Println("Renaming", appAsar, "to", _appAsar)
-	if err := os.Rename(appAsar, _appAsar); err != nil {
-		err = CheckIfErrIsCauseItsBusyRn(err)
-		fmt.Println(err)
-		return err
+	if len(failed) != 0 {
+		return errors.New("Failed to switch the version of these installs:\n" + strings.Join(failed, "\n"))
 	}
-	renamesDone = append(renamesDone, []string{appAsar, _appAsar})
+	return nil
+}
+
+func patchRenames(dir string, isSystemElectron bool) error {
+	appAsar := path.Join(dir, "app.asar")
+	_appAsar := path.Join(dir, "_app.asar")
 
+	j := NewJournal("patch", dir)
+	j.Rename(appAsar, _appAsar)
 	if isSystemElectron {
-		from, to := appAsar+".unpacked", _appAsar+".unpacked"
-		fmt.Println("Renaming", from, "to", to)
-		err := os.Rename(from, to)
-		if err != nil {
-			return err
-		}
-		renamesDone = append(renamesDone, []string{from, to})
+		j.Rename(appAsar+".unpacked", _appAsar+".unpacked")
 	}
+	j.WriteFiles(appAsar)
 
-	fmt.Println("Writing files to", appAsar)
-	if err := writeFiles(appAsar); err != nil {
+	if err := j.Run(); err != nil {
+		LogWarn(err)
 		return err
 	}
-
 	return nil
 }
 
 func (di *DiscordInstall) patch() error {
-	fmt.Println("Patching " + di.path + "...")
+	LogInfo("Patching " + di.path + "...")
 	if LatestHash != InstalledHash {
 		if err := InstallLatestBuilds(); err != nil {
 			return nil // already shown dialog so don't return same error again
@@ -152,7 +298,7 @@ func (di *DiscordInstall) patch() error {
 	PreparePatch(di)
 
 	if di.isPatched {
-		fmt.Println(di.path, "is already patched. Unpatching first...")
+		LogInfo(di.path, "is already patched. Unpatching first...")
 		if err := di.unpatch(); err != nil {
 			if errors.Is(err, os.ErrPermission) {
 				return err
@@ -170,117 +316,104 @@ func (di *DiscordInstall) patch() error {
 			return err
 		}
 	}
-	fmt.Println("Successfully patched", di.path)
+	LogInfo("Successfully patched", di.path)
 	di.isPatched = true
 
+	rememberPatchedInstall(di.path, true)
+
 	if di.isFlatpak {
-		pathElements := strings.Split(di.path, "/")
-		var name string
-		for _, e := range pathElements {
-			if strings.HasPrefix(e, "com.discordapp") {
-				name = e
-				break
-			}
-		}
+		return di.grantFlatpakAccess()
+	}
+	return nil
+}
 
-		fmt.Println("This is a flatpak. Trying to grant the Flatpak access to", FilesDir+"...")
+// grantFlatpakAccess allows this Flatpak install to read the active build
+func (di *DiscordInstall) grantFlatpakAccess() error {
+	name := di.flatpakId()
 
-		isSystemFlatpak := strings.HasPrefix(di.path, "/var")
-		var args []string
-		if !isSystemFlatpak {
-			args = append(args, "--user")
-		}
-		args = append(args, "override", name, "--filesystem="+FilesDir)
-		fullCmd := "flatpak " + strings.Join(args, " ")
-
-		fmt.Println("Running", fullCmd)
-
-		var err error
-		if !isSystemFlatpak && os.Getuid() == 0 {
-			// We are operating on a user flatpak but are root
-			actualUser := os.Getenv("SUDO_USER")
-			fmt.Println("This is a user install but we are root. Using su to run as", actualUser)
-			cmd := exec.Command("su", "-", actualUser, "-c", "sh", "-c", fullCmd)
-			cmd.Stdout = os.Stdout
-			cmd.Stderr = os.Stderr
-			err = cmd.Run()
-		} else {
-			cmd := exec.Command("flatpak", args...)
-			cmd.Stdout = os.Stdout
-			cmd.Stderr = os.Stderr
-			err = cmd.Run()
-		}
-		if err != nil {
-			return errors.New("Failed to grant Discord Flatpak access to " + FilesDir + ": " + err.Error())
-		}
+	// Grant access to all versions so switching between them doesn't need another override
+	grantDir := Ternary(path.Dir(FilesDir) == VersionsDir, VersionsDir, FilesDir)
+	LogInfo("This is a flatpak. Trying to grant the Flatpak access to", grantDir+"...")
+
+	args := []string{"override", name, "--filesystem=" + grantDir}
+	// Symlinked dev installs load their files from the checkout
+	if target, err := path.EvalSymlinks(Patcher); err == nil && path.Dir(target) != FilesDir {
+		args = append(args, "--filesystem="+path.Dir(target))
+	}
+
+	cmd := di.flatpakCommand(args...)
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	if err := RunCommand(cmd); err != nil {
+		return errors.New("Failed to grant Discord Flatpak access to " + grantDir + ": " + err.Error())
 	}
 	return nil
 }
 
-func unpatchRenames(dir string, isSystemElectron bool) (errOut error) {
-	appAsar := path.Join(dir, "app.asar")
-	appAsarTmp := path.Join(dir, "app.asar.tmp")
-	_appAsar := path.Join(dir, "_app.asar")
-
-	var renamesDone [][]string
-	defer func() {
-		if errOut != nil && len(renamesDone) > 0 {
-			fmt.Println("Failed to unpatch. Undoing partial unpatch")
-			for _, rename := range renamesDone {
-				if innerErr := os.Rename(rename[1], rename[0]); innerErr != nil {
-					fmt.Println("Failed to undo partial unpatch. This install is probably bricked.", innerErr)
-				} else {
-					fmt.Println("Successfully undid all changes")
-				}
-			}
-		} else if errOut == nil {
-			if innerErr := os.RemoveAll(appAsarTmp); innerErr != nil {
-				fmt.Println("Failed to delete temporary app.asar (patch folder) backup. This is whatever but you might want to delete it manually.", innerErr)
-			}
+// flatpakId returns the Flatpak app id of this install, like com.discordapp.Discord
+func (di *DiscordInstall) flatpakId() string {
+	for _, e := range strings.Split(di.path, "/") {
+		if strings.HasPrefix(e, "com.discordapp") {
+			return e
 		}
-	}()
+	}
+	return ""
+}
 
-	fmt.Println("Deleting", appAsar)
-	if err := os.Rename(appAsar, appAsarTmp); err != nil {
-		err = CheckIfErrIsCauseItsBusyRn(err)
-		fmt.Println(err)
-		errOut = err
-	} else {
-		renamesDone = append(renamesDone, []string{appAsar, appAsarTmp})
+// flatpakCommand creates a flatpak command that applies to the installation this Flatpak is part of.
+// User installations belong to the actual user, so the command is run as them if we are root
+func (di *DiscordInstall) flatpakCommand(args ...string) *exec.Cmd {
+	isSystemFlatpak := strings.HasPrefix(di.path, "/var")
+	if !isSystemFlatpak {
+		args = append([]string{"--user"}, args...)
 	}
+	fullCmd := "flatpak " + strings.Join(args, " ")
 
-	fmt.Println("Renaming", _appAsar, "to", appAsar)
-	if err := os.Rename(_appAsar, appAsar); err != nil {
-		err = CheckIfErrIsCauseItsBusyRn(err)
-		fmt.Println(err)
-		errOut = err
-	} else {
-		renamesDone = append(renamesDone, []string{_appAsar, appAsar})
+	LogInfo("Running", fullCmd)
+
+	if !isSystemFlatpak && os.Getuid() == 0 {
+		// We are operating on a user flatpak but are root
+		actualUser := os.Getenv("SUDO_USER")
+		LogInfo("This is a user install but we are root. Using su to run as", actualUser)
+		return exec.Command("su", "-", actualUser, "-c", "sh", "-c", fullCmd)
 	}
+	return exec.Command("flatpak", args...)
+}
+
+func unpatchRenames(dir string, isSystemElectron bool) error {
+	appAsar := path.Join(dir, "app.asar")
+	appAsarTmp := path.Join(dir, "app.asar.tmp")
+	_appAsar := path.Join(dir, "_app.asar")
 
+	j := NewJournal("unpatch", dir)
+	// The patch folder is kept as app.asar.tmp until the original asar is back in place
+	j.Rename(appAsar, appAsarTmp)
+	j.Rename(_appAsar, appAsar)
 	if isSystemElectron {
-		fmt.Println("Renaming", _appAsar+".unpacked", "to", appAsar+".unpacked")
-		if err := os.Rename(_appAsar+".unpacked", appAsar+".unpacked"); err != nil {
-			fmt.Println(err)
-			errOut = err
-		}
+		j.Rename(_appAsar+".unpacked", appAsar+".unpacked")
+	}
+	j.Delete(appAsarTmp)
+
+	if err := j.Run(); err != nil {
+		LogWarn(err)
+		return err
 	}
-	return
+	return nil
 }
 
 func (di *DiscordInstall) unpatch() error {
-	fmt.Println("Unpatching " + di.path + "...")
+	LogInfo("Unpatching " + di.path + "...")
 
 	PreparePatch(di)
 
 	if di.isSystemElectron {
-		fmt.Println("Detected as System Electron Install")
+		LogInfo("Detected as System Electron Install")
 		// See comment in Patch
 		if err := unpatchRenames(di.path, true); err != nil {
 			return err
 		}
 	} else {
-		isCanaryHack := IsDirectory(path.Join(di.appPath, "..", "app.asar"))
+		isCanaryHack := IsDiscordDirectory(path.Join(di.appPath, "..", "app.asar"))
 		if isCanaryHack {
 			if err := unpatchRenames(path.Join(di.appPath, ".."), false); err != nil {
 				return err
@@ -288,21 +421,22 @@ func (di *DiscordInstall) unpatch() error {
 		} else {
 			err := IsSafeToDelete(di.appPath)
 			if errors.Is(err, os.ErrPermission) {
-				fmt.Println("Permission to read", di.appPath, "denied")
+				LogWarn("Permission to read", di.appPath, "denied")
 				return err
 			}
-			fmt.Println("Checking if", di.appPath, "is safe to delete:", Ternary(err == nil, "Yes", "No"))
+			LogDebug("Checking if", di.appPath, "is safe to delete:", Ternary(err == nil, "Yes", "No"))
 			if err != nil {
 				return errors.New("Deleting patch folder '" + di.appPath + "' is possibly unsafe. Please do it manually: " + err.Error())
 			}
-			fmt.Println("Deleting", di.appPath)
-			err = os.RemoveAll(di.appPath)
+			LogInfo("Deleting", di.appPath)
+			err = DiscordFS.RemoveAll(di.appPath)
 			if err != nil {
 				return err
 			}
 		}
 	}
-	fmt.Println("Successfully unpatched", di.path)
+	LogInfo("Successfully unpatched", di.path)
 	di.isPatched = false
+	rememberPatchedInstall(di.path, false)
 	return nil
 }
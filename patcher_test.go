@@ -0,0 +1,317 @@
+//go:build linux
+
+/*
+ * SPDX-License-Identifier: GPL-3.0
+ * Vencord Installer, a cross platform gui/cli app for installing Vencord
+ * Copyright (c) 2023 Vendicated and Vencord contributors
+ */
+
+package main
+
+import (
+	"os"
+	path "path/filepath"
+	"reflect"
+	"testing"
+)
+
+const fakePatcher = "/home/user/.config/Vencord/versions/abc1234/patcher.js"
+
+// useMemFS makes DiscordFS a MemFS containing files, and restores everything the patch logic touches after the test
+func useMemFS(t *testing.T, files map[string]string) *MemFS {
+	t.Helper()
+
+	m := NewMemFS()
+	for name, data := range files {
+		if data == "" {
+			if err := m.MkdirAll(name); err != nil {
+				t.Fatal(err)
+			}
+		} else if err := m.AddFile(name, []byte(data)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	prevFS, prevJournalDir, prevPatcher := DiscordFS, JournalDir, Patcher
+	prevInstalledHash, prevLatestHash := InstalledHash, LatestHash
+	prevPatchedInstallsFile, prevDiscords := PatchedInstallsFile, discords
+	t.Cleanup(func() {
+		DiscordFS, JournalDir, Patcher = prevFS, prevJournalDir, prevPatcher
+		InstalledHash, LatestHash = prevInstalledHash, prevLatestHash
+		PatchedInstallsFile, discords = prevPatchedInstallsFile, prevDiscords
+	})
+
+	DiscordFS = m
+	JournalDir = t.TempDir()
+	PatchedInstallsFile = path.Join(t.TempDir(), "patched-installs")
+	discords = nil
+	Patcher = fakePatcher
+	// Nothing to download
+	InstalledHash, LatestHash = "abc1234", "abc1234"
+	return m
+}
+
+// layout returns every file in m with its content. Folders have no content
+func layout(m *MemFS) map[string]string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	files := make(map[string]string, len(m.files))
+	for name, f := range m.files {
+		files[name] = string(f.data)
+	}
+	return files
+}
+
+func assertLayout(t *testing.T, m *MemFS, want map[string]string) {
+	t.Helper()
+	if got := layout(m); !reflect.DeepEqual(got, want) {
+		t.Fatalf("unexpected layout\n got: %v\nwant: %v", got, want)
+	}
+}
+
+func parseFake(t *testing.T, p string) *DiscordInstall {
+	t.Helper()
+	di := ParseDiscord(p, "")
+	if di == nil {
+		t.Fatal("Failed to parse", p)
+	}
+	return di
+}
+
+var normalInstall = map[string]string{
+	"/opt":                             "",
+	"/opt/Discord":                     "",
+	"/opt/Discord/resources":           "",
+	"/opt/Discord/resources/app.asar":  "discord asar",
+	"/opt/Discord/resources/build.txt": "1",
+}
+
+var patchedNormalInstall = map[string]string{
+	"/opt":                                         "",
+	"/opt/Discord":                                 "",
+	"/opt/Discord/resources":                       "",
+	"/opt/Discord/resources/_app.asar":             "discord asar",
+	"/opt/Discord/resources/app.asar":              "",
+	"/opt/Discord/resources/app.asar/index.js":     `require("` + fakePatcher + `")`,
+	"/opt/Discord/resources/app.asar/package.json": string(PackageJson),
+	"/opt/Discord/resources/build.txt":             "1",
+}
+
+var systemElectronInstall = map[string]string{
+	"/usr":                               "",
+	"/usr/lib":                           "",
+	"/usr/lib/discord":                   "",
+	"/usr/lib/discord/app.asar":          "discord asar",
+	"/usr/lib/discord/app.asar.unpacked": "",
+	"/usr/lib/discord/app.asar.unpacked/a.node": "native",
+}
+
+var patchedSystemElectronInstall = map[string]string{
+	"/usr":                                "",
+	"/usr/lib":                            "",
+	"/usr/lib/discord":                    "",
+	"/usr/lib/discord/_app.asar":          "discord asar",
+	"/usr/lib/discord/_app.asar.unpacked": "",
+	"/usr/lib/discord/_app.asar.unpacked/a.node": "native",
+	"/usr/lib/discord/app.asar":                  "",
+	"/usr/lib/discord/app.asar/index.js":         `require("` + fakePatcher + `")`,
+	"/usr/lib/discord/app.asar/package.json":     string(PackageJson),
+}
+
+func TestPatchAndUnpatch(t *testing.T) {
+	m := useMemFS(t, normalInstall)
+
+	di := parseFake(t, "/opt/Discord")
+	if di.isPatched || di.isSystemElectron {
+		t.Fatal("Fresh install detected as patched or system electron")
+	}
+	if err := di.patch(); err != nil {
+		t.Fatal(err)
+	}
+	assertLayout(t, m, patchedNormalInstall)
+
+	di = parseFake(t, "/opt/Discord")
+	if !di.isPatched {
+		t.Fatal("Patched install not detected as patched")
+	}
+	if err := di.unpatch(); err != nil {
+		t.Fatal(err)
+	}
+	assertLayout(t, m, normalInstall)
+}
+
+func TestPatchPatchedInstall(t *testing.T) {
+	m := useMemFS(t, patchedNormalInstall)
+
+	// Unpatches first, so the result is the same as patching once
+	if err := parseFake(t, "/opt/Discord").patch(); err != nil {
+		t.Fatal(err)
+	}
+	assertLayout(t, m, patchedNormalInstall)
+}
+
+// The app folder next to app.asar is how installs were patched before the canary hack
+func TestUnpatchAppFolder(t *testing.T) {
+	m := useMemFS(t, map[string]string{
+		"/opt":                                    "",
+		"/opt/Discord":                            "",
+		"/opt/Discord/resources":                  "",
+		"/opt/Discord/resources/app.asar":         "discord asar",
+		"/opt/Discord/resources/app":              "",
+		"/opt/Discord/resources/app/index.js":     `require("` + fakePatcher + `")`,
+		"/opt/Discord/resources/app/package.json": string(PackageJson),
+	})
+
+	di := parseFake(t, "/opt/Discord")
+	if !di.isPatched {
+		t.Fatal("Install with app folder not detected as patched")
+	}
+	if err := di.unpatch(); err != nil {
+		t.Fatal(err)
+	}
+	assertLayout(t, m, map[string]string{
+		"/opt":                            "",
+		"/opt/Discord":                    "",
+		"/opt/Discord/resources":          "",
+		"/opt/Discord/resources/app.asar": "discord asar",
+	})
+}
+
+func TestUnpatchRefusesForeignFiles(t *testing.T) {
+	files := map[string]string{
+		"/opt":                                "",
+		"/opt/Discord":                        "",
+		"/opt/Discord/resources":              "",
+		"/opt/Discord/resources/app.asar":     "discord asar",
+		"/opt/Discord/resources/app":          "",
+		"/opt/Discord/resources/app/index.js": "something else",
+		"/opt/Discord/resources/app/main.js":  "not ours",
+	}
+	m := useMemFS(t, files)
+
+	if err := parseFake(t, "/opt/Discord").unpatch(); err == nil {
+		t.Fatal("Unpatch deleted a folder with foreign files")
+	}
+	assertLayout(t, m, files)
+}
+
+func TestPatchSystemElectron(t *testing.T) {
+	m := useMemFS(t, systemElectronInstall)
+
+	di := parseFake(t, "/usr/lib/discord")
+	if !di.isSystemElectron || di.isPatched {
+		t.Fatal("System electron install not detected")
+	}
+	if err := di.patch(); err != nil {
+		t.Fatal(err)
+	}
+	assertLayout(t, m, patchedSystemElectronInstall)
+
+	di = parseFake(t, "/usr/lib/discord")
+	if !di.isPatched {
+		t.Fatal("Patched system electron install not detected as patched")
+	}
+	if err := di.unpatch(); err != nil {
+		t.Fatal(err)
+	}
+	assertLayout(t, m, systemElectronInstall)
+}
+
+func TestUninstallOpenAsar(t *testing.T) {
+	m := useMemFS(t, map[string]string{
+		"/opt":                            "",
+		"/opt/Discord":                    "",
+		"/opt/Discord/resources":          "",
+		"/opt/Discord/resources/app.asar": "OpenAsar",
+		"/opt/Discord/resources/app.asar.original": "discord asar",
+	})
+
+	di := parseFake(t, "/opt/Discord")
+	if !di.IsOpenAsar() {
+		t.Fatal("OpenAsar not detected")
+	}
+	if err := di.UninstallOpenAsar(); err != nil {
+		t.Fatal(err)
+	}
+	assertLayout(t, m, map[string]string{
+		"/opt":                            "",
+		"/opt/Discord":                    "",
+		"/opt/Discord/resources":          "",
+		"/opt/Discord/resources/app.asar": "discord asar",
+	})
+}
+
+// Installs patched at a custom location aren't found by FindDiscords, but have to be repointed too
+func TestRepointCustomLocation(t *testing.T) {
+	m := useMemFS(t, normalInstall)
+
+	if err := parseFake(t, "/opt/Discord").patch(); err != nil {
+		t.Fatal(err)
+	}
+
+	Patcher = "/home/user/.config/Vencord/versions/def5678/patcher.js"
+	if err := RepointPatchedInstalls(); err != nil {
+		t.Fatal(err)
+	}
+	if got := string(m.files["/opt/Discord/resources/app.asar/index.js"].data); got != `require("`+Patcher+`")` {
+		t.Fatal("Custom location not repointed, index.js is", got)
+	}
+
+	if err := parseFake(t, "/opt/Discord").unpatch(); err != nil {
+		t.Fatal(err)
+	}
+	if installs := PatchedInstalls(); len(installs) != 0 {
+		t.Fatal("Unpatched install still remembered:", installs[0].path)
+	}
+}
+
+func TestPruneKeepsLoadedVersions(t *testing.T) {
+	useMemFS(t, normalInstall)
+
+	prevVersionsDir := VersionsDir
+	t.Cleanup(func() { VersionsDir = prevVersionsDir })
+	VersionsDir = t.TempDir()
+
+	versions := []string{"aaaaaaa", "bbbbbbb", "ccccccc", "ddddddd", "eeeeeee", "fffffff", "1111111", "2222222"}
+	for _, version := range versions {
+		if err := os.Mkdir(path.Join(VersionsDir, version), 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// The oldest version is still loaded by an install that couldn't be repointed
+	Patcher = path.Join(VersionsDir, versions[0], "patcher.js")
+	if err := parseFake(t, "/opt/Discord").patch(); err != nil {
+		t.Fatal(err)
+	}
+
+	InstalledHash = versions[len(versions)-1]
+	PruneVersions()
+
+	if _, err := os.Stat(path.Join(VersionsDir, versions[0])); err != nil {
+		t.Fatal("Deleted a version a patched install still loads:", err)
+	}
+	if _, err := os.Stat(path.Join(VersionsDir, InstalledHash)); err != nil {
+		t.Fatal("Deleted the active version:", err)
+	}
+	if entries, _ := os.ReadDir(VersionsDir); len(entries) != KeptVersions+1 {
+		t.Fatal("Expected", KeptVersions+1, "versions to be kept, got", len(entries))
+	}
+}
+
+// The installer's own files are never in DiscordFS, even during dry runs
+func TestInstallerPathsIgnoreDiscordFS(t *testing.T) {
+	useMemFS(t, normalInstall)
+
+	dir := t.TempDir()
+	if !ExistsFile(dir) || !IsDirectory(dir) {
+		t.Fatal("Installer folder not found on disk")
+	}
+	if ExistsDiscordFile(dir) {
+		t.Fatal("Installer folder found in DiscordFS")
+	}
+	if !IsDiscordDirectory("/opt/Discord/resources") || ExistsFile("/opt/Discord/resources/app.asar") {
+		t.Fatal("Discord install not only found in DiscordFS")
+	}
+}
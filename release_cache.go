@@ -0,0 +1,102 @@
+/*
+ * SPDX-License-Identifier: GPL-3.0
+ * Vencord Installer, a cross platform gui/cli app for installing Vencord
+ * Copyright (c) 2023 Vendicated and Vencord contributors
+ */
+
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"os"
+	path "path/filepath"
+	"time"
+)
+
+// CacheDir stores release JSON with its ETag, so unchanged releases can be revalidated
+// without counting towards GitHub's rate limit and are still available offline
+var CacheDir string
+
+// ReadOnlyCache stops releases from being cached, so a dry run doesn't write anything
+var ReadOnlyCache bool
+
+type cachedRelease struct {
+	Url       string          `json:"url"`
+	ETag      string          `json:"etag"`
+	FetchedAt time.Time       `json:"fetchedAt"`
+	Body      json.RawMessage `json:"body"`
+}
+
+func releaseCacheFile(url string) string {
+	sum := sha256.Sum256([]byte(url))
+	return path.Join(CacheDir, "release-"+hex.EncodeToString(sum[:8])+".json")
+}
+
+func readReleaseCache(url string) *cachedRelease {
+	b, err := os.ReadFile(releaseCacheFile(url))
+	if err != nil {
+		if !errors.Is(err, os.ErrNotExist) {
+			LogWarn("Failed to read cached release", err)
+		}
+		return nil
+	}
+
+	var cached cachedRelease
+	if err = json.Unmarshal(b, &cached); err != nil || cached.Url != url {
+		LogWarn("Ignoring invalid cached release for", url)
+		return nil
+	}
+	return &cached
+}
+
+func writeReleaseCache(cached *cachedRelease) {
+	if ReadOnlyCache || dryRunPlan() != nil {
+		return
+	}
+	if err := os.MkdirAll(CacheDir, 0755); err != nil {
+		LogWarn("Failed to create", CacheDir+":", err)
+		return
+	}
+
+	b, err := json.Marshal(cached)
+	if err != nil {
+		return
+	}
+
+	file := releaseCacheFile(cached.Url)
+	if err = os.WriteFile(file, b, 0644); err != nil {
+		LogWarn("Failed to cache release", err)
+		return
+	}
+	_ = FixOwnership(CacheDir)
+}
+
+// Release decodes the cached release. isStale marks it as a fallback because fetching failed
+func (c *cachedRelease) Release(isStale bool) (*GithubRelease, error) {
+	var data GithubRelease
+	if err := json.Unmarshal(c.Body, &data); err != nil {
+		return nil, err
+	}
+	data.FetchedAt = c.FetchedAt
+	data.IsStale = isStale
+	return &data, nil
+}
+
+// GetCachedRelease returns the last release fetched from url, marked as stale, if there is one
+func GetCachedRelease(url string) (*GithubRelease, bool) {
+	cached := readReleaseCache(url)
+	if cached == nil {
+		return nil, false
+	}
+
+	data, err := cached.Release(true)
+	if err != nil {
+		return nil, false
+	}
+
+	LogInfo("Using cached release from", url, "fetched", FormatAge(data.FetchedAt))
+	return data, true
+}
@@ -0,0 +1,203 @@
+/*
+ * SPDX-License-Identifier: GPL-3.0
+ * Vencord Installer, a cross platform gui/cli app for installing Vencord
+ * Copyright (c) 2023 Vendicated and Vencord contributors
+ */
+
+package main
+
+import (
+	"errors"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// SourceGithub is the GitHub releases API. Pinned tags are resolved through /releases/tags/<tag>
+	SourceGithub = "github"
+	// SourceVencord is a vencord.dev style endpoint that only ever serves the latest release
+	SourceVencord = "vencord"
+	// SourceMirror is a self-hosted copy of the release JSON. Relative asset urls are resolved against it
+	SourceMirror = "mirror"
+)
+
+const DefaultSourceTimeout = 30 * time.Second
+
+// ReleaseSource is somewhere Venticord release data can be fetched from
+type ReleaseSource struct {
+	Kind string `json:"type"`
+	Url  string `json:"url"`
+	// Go duration like "10s". Defaults to DefaultSourceTimeout
+	Timeout string `json:"timeout,omitempty"`
+	// How often to retry on network errors or temporary server errors
+	Retries int `json:"retries,omitempty"`
+}
+
+var DefaultReleaseSources = []ReleaseSource{
+	{Kind: SourceGithub, Url: ReleaseUrl},
+	{Kind: SourceVencord, Url: ReleaseUrlFallback},
+}
+
+// ReleaseSources are tried in order until one of them works
+var ReleaseSources = DefaultReleaseSources
+
+// ParseReleaseSource parses the -release-source flag, "type=url[,timeout=10s][,retries=2]"
+func ParseReleaseSource(s string) (ReleaseSource, error) {
+	parts := strings.Split(s, ",")
+
+	var source ReleaseSource
+	source.Kind, source.Url, _ = strings.Cut(parts[0], "=")
+	for _, part := range parts[1:] {
+		key, value, _ := strings.Cut(part, "=")
+		switch key {
+		case "timeout":
+			source.Timeout = value
+		case "retries":
+			retries, err := strconv.Atoi(value)
+			if err != nil {
+				return source, errors.New("Invalid retries '" + value + "'")
+			}
+			source.Retries = retries
+		default:
+			return source, errors.New("Unknown release source option '" + key + "'")
+		}
+	}
+
+	return source, source.Validate()
+}
+
+func (s *ReleaseSource) Validate() error {
+	switch s.Kind {
+	case SourceGithub, SourceVencord, SourceMirror:
+	default:
+		return errors.New("Unknown release source type '" + s.Kind + "'. Must be one of [github|vencord|mirror]")
+	}
+
+	if u, err := url.Parse(s.Url); err != nil || (u.Scheme != "http" && u.Scheme != "https") {
+		return errors.New("Release source url '" + s.Url + "' is not a http(s) url")
+	}
+
+	if s.Timeout != "" {
+		if _, err := time.ParseDuration(s.Timeout); err != nil {
+			return errors.New("Invalid timeout '" + s.Timeout + "': " + err.Error())
+		}
+	}
+
+	if s.Retries < 0 {
+		return errors.New("retries must not be negative")
+	}
+
+	return nil
+}
+
+func (s *ReleaseSource) String() string {
+	return s.Kind + " (" + s.Url + ")"
+}
+
+// Fetch fetches the release with the given tag, or the latest one if tag is empty
+func (s *ReleaseSource) Fetch(tag string) (*GithubRelease, error) {
+	timeout := DefaultSourceTimeout
+	if s.Timeout != "" {
+		timeout, _ = time.ParseDuration(s.Timeout)
+	}
+	client := *HttpClient
+	client.Timeout = timeout
+
+	releaseUrl := s.releaseUrl(tag)
+
+	var data *GithubRelease
+	var err error
+	for attempt := 0; attempt <= s.Retries; attempt++ {
+		if attempt != 0 {
+			delay := time.Duration(attempt) * time.Second
+			LogInfo("Retrying", releaseUrl, "in", delay)
+			time.Sleep(delay)
+		}
+
+		data, err = fetchRelease(&client, releaseUrl)
+
+		// Retrying won't help until the rate limit resets, which usually takes way longer than our backoff
+		var statusErr *HttpStatusError
+		var rateErr *RateLimitError
+		if err == nil || (errors.As(err, &statusErr) && !statusErr.IsTemporary()) || errors.As(err, &rateErr) {
+			break
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return s.checkRelease(data, tag)
+}
+
+// FetchCached returns the cached copy of what Fetch would return, if there is one
+func (s *ReleaseSource) FetchCached(tag string) (*GithubRelease, bool) {
+	data, ok := GetCachedRelease(s.releaseUrl(tag))
+	if !ok {
+		return nil, false
+	}
+
+	data, err := s.checkRelease(data, tag)
+	return data, err == nil
+}
+
+func (s *ReleaseSource) releaseUrl(tag string) string {
+	if tag != "" && s.Kind == SourceGithub {
+		return strings.TrimSuffix(s.Url, "/latest") + "/tags/" + url.PathEscape(tag)
+	}
+	return s.Url
+}
+
+func (s *ReleaseSource) checkRelease(data *GithubRelease, tag string) (*GithubRelease, error) {
+	data.SourceUrl = s.releaseUrl(tag)
+
+	// Only GitHub can look up tags, so make sure the others didn't give us something else
+	if tag != "" && data.TagName != tag {
+		return nil, errors.New("Served release " + data.TagName + " instead of " + tag)
+	}
+
+	if s.Kind == SourceMirror {
+		base, _ := url.Parse(s.releaseUrl(tag))
+		for i, ass := range data.Assets {
+			if u, err := base.Parse(ass.DownloadURL); err == nil {
+				data.Assets[i].DownloadURL = u.String()
+			}
+		}
+	}
+
+	return data, nil
+}
+
+// FetchRelease tries all ReleaseSources in order and returns the first release that could be fetched
+func FetchRelease(tag string) (*GithubRelease, error) {
+	var errs []string
+	for i := range ReleaseSources {
+		source := &ReleaseSources[i]
+
+		data, err := source.Fetch(tag)
+		if err == nil {
+			return data, nil
+		}
+
+		LogWarn("Failed to fetch release from", source.String()+":", err)
+		errs = append(errs, source.String()+": "+err.Error())
+	}
+
+	// Better outdated than nothing, e.g. when offline
+	var newest *GithubRelease
+	for i := range ReleaseSources {
+		if data, ok := ReleaseSources[i].FetchCached(tag); ok && (newest == nil || data.FetchedAt.After(newest.FetchedAt)) {
+			newest = data
+		}
+	}
+	if newest != nil {
+		return newest, nil
+	}
+
+	if len(errs) == 1 {
+		return nil, errors.New(errs[0])
+	}
+	return nil, errors.New("All release sources failed:\n" + strings.Join(errs, "\n"))
+}
@@ -11,8 +11,10 @@ import (
 	"fmt"
 	"os"
 	"runtime"
+	"strconv"
 	"strings"
 	"syscall"
+	"time"
 )
 
 func ArrayIncludes[T comparable](arr []T, v T) bool {
@@ -24,19 +26,39 @@ func ArrayIncludes[T comparable](arr []T, v T) bool {
 	return false
 }
 
+// ExistsFile and IsDirectory are for the installer's own files. Use ExistsDiscordFile and IsDiscordDirectory
+// for files of Discord installs, so they see what a dry run or the tests did to them
 func ExistsFile(path string) bool {
-	_, err := os.Stat(path)
-	fmt.Println("Checking if", path, "exists:", Ternary(err == nil, "Yes", "No"))
-	return err == nil
+	return existsIn(os.Stat, path)
 }
 
 func IsDirectory(path string) bool {
-	s, err := os.Stat(path)
+	return isDirectoryIn(os.Stat, path)
+}
+
+// ExistsDiscordFile is like ExistsFile, but goes through DiscordFS
+func ExistsDiscordFile(path string) bool {
+	return existsIn(DiscordFS.Stat, path)
+}
+
+// IsDiscordDirectory is like IsDirectory, but goes through DiscordFS
+func IsDiscordDirectory(path string) bool {
+	return isDirectoryIn(DiscordFS.Stat, path)
+}
+
+func existsIn(stat func(string) (os.FileInfo, error), path string) bool {
+	_, err := stat(path)
+	LogDebug("Checking if", path, "exists:", Ternary(err == nil, "Yes", "No"))
+	return err == nil
+}
+
+func isDirectoryIn(stat func(string) (os.FileInfo, error), path string) bool {
+	s, err := stat(path)
 	if err != nil {
-		fmt.Println("Error while checking if", path, "is directory:", err)
+		LogDebug("Error while checking if", path, "is directory:", err)
 		return false
 	}
-	fmt.Println("Checking if", path, "is directory:", Ternary(s.IsDir(), "Yes", "No"))
+	LogDebug("Checking if", path, "is directory:", Ternary(s.IsDir(), "Yes", "No"))
 	return s.IsDir()
 }
 
@@ -63,7 +85,14 @@ func Ptr[T any](v T) *T {
 	return &v
 }
 
+// ErrDiscordBusy is wrapped by errors returned from CheckIfErrIsCauseItsBusyRn
+var ErrDiscordBusy = errors.New("Cannot patch because Discord's files are used by a different process!")
+
 func CheckIfErrIsCauseItsBusyRn(err error) error {
+	// FaultFS simulates busy files on every OS
+	if errors.Is(err, ErrFileBusy) {
+		return fmt.Errorf("%w\nMake sure you close Discord before trying to patch!", ErrDiscordBusy)
+	}
 	if runtime.GOOS != "windows" {
 		return err
 	}
@@ -71,16 +100,45 @@ func CheckIfErrIsCauseItsBusyRn(err error) error {
 	// bruhhhh
 	if linkError, ok := err.(*os.LinkError); ok {
 		if errno, ok := linkError.Err.(syscall.Errno); ok && errno == 32 /* ERROR_SHARING_VIOLATION */ {
-			return errors.New(
-				"Cannot patch because Discord's files are used by a different process!" +
-					"\nMake sure you close Discord before trying to patch!",
-			)
+			return fmt.Errorf("%w\nMake sure you close Discord before trying to patch!", ErrDiscordBusy)
 		}
 	}
 
 	return err
 }
 
+// FormatAge describes how long ago t was, like "3 hours ago"
+func FormatAge(t time.Time) string {
+	d := time.Since(t)
+	switch {
+	case d < time.Minute:
+		return "just now"
+	case d < time.Hour:
+		return pluralize(int(d.Minutes()), "minute") + " ago"
+	case d < 48*time.Hour:
+		return pluralize(int(d.Hours()), "hour") + " ago"
+	default:
+		return pluralize(int(d.Hours()/24), "day") + " ago"
+	}
+}
+
+// FormatDuration describes d in hours and minutes, like "1 hour 5 minutes"
+func FormatDuration(d time.Duration) string {
+	hours, minutes := int(d.Hours()), int(d.Minutes())%60
+	switch {
+	case hours == 0:
+		return pluralize(minutes, "minute")
+	case minutes == 0:
+		return pluralize(hours, "hour")
+	default:
+		return pluralize(hours, "hour") + " " + pluralize(minutes, "minute")
+	}
+}
+
+func pluralize(n int, word string) string {
+	return strconv.Itoa(n) + " " + word + Ternary(n == 1, "", "s")
+}
+
 func Unwrap[T any](v T, err error) T {
 	if err != nil {
 		panic(err)
@@ -0,0 +1,187 @@
+/*
+ * SPDX-License-Identifier: GPL-3.0
+ * Vencord Installer, a cross platform gui/cli app for installing Vencord
+ * Copyright (c) 2023 Vendicated and Vencord contributors
+ */
+
+package main
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"os"
+	path "path/filepath"
+	"sort"
+	"strings"
+)
+
+// IntegrityReport lists everything CheckIntegrity found wrong
+type IntegrityReport struct {
+	// Files in FilesDir that are missing or changed since they were installed
+	BrokenFiles []string `json:"brokenFiles,omitempty"`
+	// Patched installs whose index.js doesn't load the active patcher.js
+	BrokenInstalls []string `json:"brokenInstalls,omitempty"`
+}
+
+func (r *IntegrityReport) IsOk() bool {
+	return len(r.BrokenFiles) == 0 && len(r.BrokenInstalls) == 0
+}
+
+func (r *IntegrityReport) String() string {
+	if r.IsOk() {
+		return "Everything is intact"
+	}
+
+	var sb strings.Builder
+	if len(r.BrokenFiles) != 0 {
+		sb.WriteString("Broken Venticord files in " + FilesDir + ":\n- " + strings.Join(r.BrokenFiles, "\n- ") + "\n")
+	}
+	if len(r.BrokenInstalls) != 0 {
+		sb.WriteString("Patched installs not loading " + Patcher + ":\n- " + strings.Join(r.BrokenInstalls, "\n- ") + "\n")
+	}
+	return strings.TrimSuffix(sb.String(), "\n")
+}
+
+// CheckIntegrity compares the files in FilesDir against InstalledManifest and makes sure
+// every patched install still points at the active patcher.js
+func CheckIntegrity() *IntegrityReport {
+	LogInfo("Checking integrity of", FilesDir)
+	return &IntegrityReport{
+		BrokenFiles:    checkFiles(),
+		BrokenInstalls: checkPatchedInstalls(),
+	}
+}
+
+func checkFiles() []string {
+	var broken []string
+
+	if InstalledManifest == nil {
+		// Installed by an older installer, so all we can do is make sure the files are there
+		for _, file := range DistFiles {
+			if !ExistsFile(path.Join(FilesDir, file)) {
+				broken = append(broken, file+": missing")
+			}
+		}
+		return broken
+	}
+
+	for name, expected := range InstalledManifest.Files {
+		sum, err := hashFile(path.Join(FilesDir, name))
+		switch {
+		case errors.Is(err, os.ErrNotExist):
+			broken = append(broken, name+": missing")
+		case err != nil:
+			broken = append(broken, name+": "+err.Error())
+		case expected != "" && hex.EncodeToString(sum) != expected:
+			broken = append(broken, name+": modified")
+		}
+	}
+
+	sort.Strings(broken)
+	return broken
+}
+
+func checkPatchedInstalls() []string {
+	patcherPath, _ := json.Marshal(Patcher)
+	expected := "require(" + string(patcherPath) + ")"
+
+	var broken []string
+	for _, di := range PatchedInstalls() {
+		dir := di.patchDir()
+		if dir == "" {
+			continue
+		}
+
+		b, err := DiscordFS.ReadFile(path.Join(dir, "index.js"))
+		if err != nil {
+			broken = append(broken, di.path+": "+err.Error())
+		} else if strings.TrimSpace(string(b)) != expected {
+			broken = append(broken, di.path+": loads something else")
+		}
+	}
+	return broken
+}
+
+// RepairIntegrity fixes what CheckIntegrity found. Broken files are fixed by reinstalling the active version from
+// where it came from. Returns the report of a second check afterwards
+func RepairIntegrity(report *IntegrityReport) (*IntegrityReport, error) {
+	if report.IsOk() {
+		return report, nil
+	}
+
+	if len(report.BrokenFiles) != 0 {
+		if IsDevInstall && DevCheckout == "" {
+			return report, errors.New("Not repairing files of a dev install. Rebuild Vencord instead")
+		}
+
+		// Also repoints all patched installs
+		if err := reinstallActiveVersion(); err != nil {
+			return report, err
+		}
+	} else {
+		LogInfo("Repointing patched installs at", Patcher)
+		if err := RepointPatchedInstalls(); err != nil {
+			return report, err
+		}
+	}
+
+	after := CheckIntegrity()
+	if !after.IsOk() {
+		return after, errors.New("Some problems remain after repairing:\n" + after.String())
+	}
+	return after, nil
+}
+
+// reinstallActiveVersion installs the active version again from the source recorded in InstalledManifest.
+// Repairing must not switch to another build, so this refuses if the source doesn't have that version anymore
+func reinstallActiveVersion() error {
+	manifest := InstalledManifest
+	if manifest == nil {
+		return errors.New("Can't repair " + FilesDir + " as it has no " + InstallManifestName + ", so it's unknown where it came from. Reinstall Venticord instead")
+	}
+
+	version, source := manifest.Version, manifest.Source
+	LogInfo("Reinstalling", version, "from", source, "to repair broken files")
+
+	switch {
+	case strings.HasPrefix(source, "http://") || strings.HasPrefix(source, "https://"):
+		// Resolves relative asset urls like a mirror but leaves absolute ones alone, so this works for every source type
+		releaseSource := ReleaseSource{Kind: SourceMirror, Url: source}
+		release, err := releaseSource.Fetch("")
+		if err != nil {
+			cached, ok := releaseSource.FetchCached("")
+			if !ok {
+				return errors.New("Can't repair " + version + " as fetching " + source + " failed: " + err.Error())
+			}
+			release = cached
+		}
+		if release.Hash() != version {
+			return errors.New("Can't repair " + version + " as " + source + " now serves version " + release.Hash() + ". " +
+				"Reinstall Venticord to switch to it, or roll back to another installed version")
+		}
+		return installRelease(release)
+
+	case strings.HasPrefix(version, "dev-"):
+		// Dev versions are installed from the dist folder of a checkout
+		hash, err := GetCheckoutHash(path.Dir(source))
+		if err != nil {
+			return errors.New("Can't repair " + version + ": " + err.Error())
+		}
+		if "dev-"+hash != version {
+			return errors.New("Can't repair " + version + " as the checkout at " + path.Dir(source) + " is at " + hash + " now. " +
+				"Install from it again to switch to that build")
+		}
+		// Keep linking the checkout if it was linked before
+		if info, err := os.Lstat(path.Join(FilesDir, "patcher.js")); err == nil && info.Mode()&os.ModeSymlink != 0 {
+			return linkCheckout(source, version)
+		}
+		return copyCheckout(source, version)
+
+	default:
+		if _, err := installBundle(source, version); err != nil {
+			return errors.New("Can't repair " + version + ": " + err.Error())
+		}
+		return nil
+	}
+}
@@ -0,0 +1,180 @@
+/*
+ * SPDX-License-Identifier: GPL-3.0
+ * Vencord Installer, a cross platform gui/cli app for installing Vencord
+ * Copyright (c) 2023 Vendicated and Vencord contributors
+ */
+
+package main
+
+import (
+	"errors"
+	"os"
+	path "path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Every installed build lives in its own folder in VersionsDir, named after its hash.
+// ActiveVersionFile contains the hash of the one FilesDir points at
+var VersionsDir string
+var ActiveVersionFile string
+
+// KeptVersions is how many builds are kept for rolling back, including the active one
+const KeptVersions = 5
+
+// IsValidVersion reports whether hash can safely be used as a folder name in VersionsDir
+func IsValidVersion(hash string) bool {
+	if hash == "" || hash == "." || hash == ".." || strings.ContainsAny(hash, `/\:`) {
+		return false
+	}
+	return !strings.HasSuffix(hash, ".staging") && !strings.HasSuffix(hash, ".old")
+}
+
+// ReadActiveVersion returns the hash of the active build or an empty string if there is none
+func ReadActiveVersion() string {
+	b, err := os.ReadFile(ActiveVersionFile)
+	if err != nil {
+		if !errors.Is(err, os.ErrNotExist) {
+			LogWarn("Failed to read", ActiveVersionFile+":", err)
+		}
+		return ""
+	}
+
+	hash := strings.TrimSpace(string(b))
+	if !IsValidVersion(hash) || !ExistsFile(path.Join(VersionsDir, hash)) {
+		LogWarn("Ignoring invalid active version", hash)
+		return ""
+	}
+	return hash
+}
+
+// InstalledVersions returns the hashes of all builds in VersionsDir, most recently installed first.
+// The order comes from their InstallManifest. Builds installed before there were manifests come last
+func InstalledVersions() []string {
+	entries, err := os.ReadDir(VersionsDir)
+	if err != nil {
+		if !errors.Is(err, os.ErrNotExist) {
+			LogWarn("Error during readdir "+VersionsDir+":", err)
+		}
+		return nil
+	}
+
+	var versions []string
+	installedAt := make(map[string]time.Time)
+	modTimes := make(map[string]time.Time)
+	for _, entry := range entries {
+		name := entry.Name()
+		if !IsValidVersion(name) {
+			continue
+		}
+		// Stat instead of entry.Info to follow symlinked dev installs
+		info, err := os.Stat(path.Join(VersionsDir, name))
+		if err != nil || !info.IsDir() {
+			continue
+		}
+		versions = append(versions, name)
+		if manifest, err := ReadInstallManifest(path.Join(VersionsDir, name)); err == nil {
+			installedAt[name] = manifest.InstalledAt
+		} else {
+			modTimes[name] = info.ModTime()
+		}
+	}
+
+	sort.SliceStable(versions, func(i, j int) bool {
+		a, aOk := installedAt[versions[i]]
+		b, bOk := installedAt[versions[j]]
+		if aOk != bOk {
+			return aOk
+		}
+		if aOk {
+			return a.After(b)
+		}
+		return modTimes[versions[i]].After(modTimes[versions[j]])
+	})
+	return versions
+}
+
+// PruneVersions deletes the least recently installed builds so only KeptVersions remain. Never deletes the active one,
+// or one that a patched install still loads because it couldn't be repointed
+func PruneVersions() {
+	inUse := map[string]bool{InstalledHash: true}
+	for _, di := range PatchedInstalls() {
+		if patcher := di.loadedPatcher(); patcher != "" && path.Dir(path.Dir(patcher)) == VersionsDir {
+			inUse[path.Base(path.Dir(patcher))] = true
+		}
+	}
+
+	kept := 1 // The active version
+	for _, version := range InstalledVersions() {
+		if inUse[version] {
+			continue
+		}
+		if kept < KeptVersions {
+			kept++
+			continue
+		}
+
+		LogInfo("Deleting old version", version)
+		// Only removes the link of symlinked dev installs, not the checkout
+		if err := os.RemoveAll(path.Join(VersionsDir, version)); err != nil {
+			LogWarn("Failed to delete", version+":", err)
+		}
+	}
+}
+
+// ActivateVersion makes the build hash the one used by all patched installs
+func ActivateVersion(hash string) error {
+	if !IsValidVersion(hash) {
+		return errors.New("Invalid version '" + hash + "'")
+	}
+
+	dir := path.Join(VersionsDir, hash)
+	if !ExistsFile(path.Join(dir, "patcher.js")) {
+		return errors.New("Version " + hash + " is not installed")
+	}
+
+	LogInfo("Activating version", hash)
+
+	// Write to a temporary file first so the pointer is never half written
+	tmpFile := ActiveVersionFile + ".tmp"
+	if err := os.WriteFile(tmpFile, []byte(hash), 0644); err != nil {
+		return err
+	}
+	if err := os.Rename(tmpFile, ActiveVersionFile); err != nil {
+		_ = os.Remove(tmpFile)
+		return err
+	}
+	_ = FixOwnership(ActiveVersionFile)
+
+	// Versions installed by older installers have no manifest
+	manifest, _ := ReadInstallManifest(dir)
+
+	StateLock.Lock()
+	FilesDir = dir
+	Patcher = path.Join(FilesDir, "patcher.js")
+	InstalledHash = hash
+	InstalledManifest = manifest
+	StateLock.Unlock()
+
+	return RepointPatchedInstalls()
+}
+
+// RollbackVersion switches to the installed build hash. If hash is empty,
+// the most recent build before the active one is used
+func RollbackVersion(hash string) error {
+	if hash == "" {
+		for _, version := range InstalledVersions() {
+			if version != InstalledHash {
+				hash = version
+				break
+			}
+		}
+		if hash == "" {
+			return errors.New("There is no other version to roll back to")
+		}
+	}
+
+	LogInfo("Rolling back to", hash)
+	return ActivateVersion(hash)
+}